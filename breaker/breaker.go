@@ -0,0 +1,177 @@
+package breaker
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultK       = 1.5
+	defaultWindow  = time.Second * 10
+	defaultBuckets = 40
+	// the requests less than protection are never dropped
+	defaultProtection = 5
+)
+
+// ErrServiceUnavailable is returned when the breaker drops the request.
+var ErrServiceUnavailable = errors.New("circuit breaker is open")
+
+// states of the breaker
+const (
+	StateClosed State = iota
+	StateOpen
+)
+
+type (
+	State int
+
+	// Acceptable checks if the error is acceptable, which doesn't count as a failure.
+	Acceptable func(err error) bool
+
+	// StateListener is called when the state of the breaker changes.
+	StateListener func(name string, from, to State)
+
+	// Breaker is a circuit breaker, it fails fast when the requests keep failing.
+	Breaker interface {
+		Name() string
+		State() State
+		// AddListener adds a listener to be notified on state changes.
+		AddListener(listener StateListener)
+		Do(req func() error) error
+		DoWithAcceptable(req func() error, acceptable Acceptable) error
+	}
+
+	// Conf is the thresholds of the breaker, zero values are replaced with the defaults.
+	Conf struct {
+		// K is the multiplier of the accepts, the smaller K is, the more aggressive the breaker is.
+		K float64 `json:",default=1.5"`
+		// Window is the duration of the sliding window to count the requests in.
+		Window time.Duration `json:",default=10s"`
+		// Buckets is the number of buckets the window is split into.
+		Buckets int `json:",default=40"`
+		// Protection is the number of requests in the window that are never dropped.
+		Protection int64 `json:",default=5"`
+	}
+
+	// googleBreaker is the adaptive throttling in Google SRE, the drop ratio is:
+	// max(0, (requests - protection - K * accepts) / (requests + 1))
+	// the breaker is open while the drop ratio is above 0.
+	googleBreaker struct {
+		name       string
+		k          float64
+		protection int64
+		stat       *rollingWindow
+		state      State
+		listeners  []StateListener
+		r          *rand.Rand
+		lock       sync.Mutex
+	}
+)
+
+// NewBreaker returns a Breaker with the given name and thresholds.
+func NewBreaker(name string, c Conf) Breaker {
+	if c.K <= 0 {
+		c.K = defaultK
+	}
+	if c.Window <= 0 {
+		c.Window = defaultWindow
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = defaultBuckets
+	}
+	if c.Protection <= 0 {
+		c.Protection = defaultProtection
+	}
+
+	return &googleBreaker{
+		name:       name,
+		k:          c.K,
+		protection: c.Protection,
+		stat:       newRollingWindow(c.Buckets, c.Window/time.Duration(c.Buckets)),
+		r:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+func (b *googleBreaker) Name() string {
+	return b.name
+}
+
+func (b *googleBreaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+func (b *googleBreaker) AddListener(listener StateListener) {
+	b.lock.Lock()
+	b.listeners = append(b.listeners, listener)
+	b.lock.Unlock()
+}
+
+func (b *googleBreaker) Do(req func() error) error {
+	return b.DoWithAcceptable(req, func(err error) bool {
+		return err == nil
+	})
+}
+
+func (b *googleBreaker) DoWithAcceptable(req func() error, acceptable Acceptable) error {
+	if err := b.accept(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			b.stat.add(false)
+			panic(p)
+		}
+	}()
+
+	err := req()
+	b.stat.add(acceptable(err))
+
+	return err
+}
+
+func (b *googleBreaker) accept() error {
+	accepts, total := b.stat.reduce()
+	weightedAccepts := b.k * float64(accepts)
+	dropRatio := math.Max(0, (float64(total-b.protection)-weightedAccepts)/float64(total+1))
+
+	b.lock.Lock()
+	var listeners []StateListener
+	from, to := b.state, StateClosed
+	if dropRatio > 0 {
+		to = StateOpen
+	}
+	if from != to {
+		b.state = to
+		listeners = b.listeners
+	}
+	drop := dropRatio > 0 && b.r.Float64() < dropRatio
+	b.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(b.name, from, to)
+	}
+
+	if drop {
+		return ErrServiceUnavailable
+	}
+
+	return nil
+}
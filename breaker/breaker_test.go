@@ -0,0 +1,108 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test")
+
+func TestBreakerAcceptsHealthy(t *testing.T) {
+	b := NewBreaker("test", Conf{})
+	for i := 0; i < 1000; i++ {
+		if err := b.Do(func() error {
+			return nil
+		}); err != nil {
+			t.Fatalf("expected the healthy requests accepted, got %v", err)
+		}
+	}
+
+	if b.State() != StateClosed {
+		t.Errorf("expected the breaker closed, got %v", b.State())
+	}
+}
+
+func TestBreakerRejectsFailing(t *testing.T) {
+	b := NewBreaker("test", Conf{})
+	var from, to State = -1, -1
+	b.AddListener(func(name string, f, t State) {
+		from, to = f, t
+	})
+
+	// the requests within the protection are never dropped
+	for i := 0; i < defaultProtection; i++ {
+		if err := b.Do(failing); err != errTest {
+			t.Fatalf("expected the protected requests accepted, got %v", err)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		_ = b.Do(failing)
+	}
+
+	var rejects int
+	for i := 0; i < 1000; i++ {
+		if b.Do(failing) == ErrServiceUnavailable {
+			rejects++
+		}
+	}
+	// the drop ratio is (total - protection) / (total + 1), above 90% after 100 failures
+	if rejects < 800 {
+		t.Errorf("expected most of the failing requests rejected, got %d of 1000", rejects)
+	}
+	if b.State() != StateOpen || from != StateClosed || to != StateOpen {
+		t.Errorf("expected the breaker open and the listener notified, got %v from %v to %v", b.State(), from, to)
+	}
+}
+
+func TestBreakerAcceptable(t *testing.T) {
+	b := NewBreaker("test", Conf{})
+	for i := 0; i < 1000; i++ {
+		err := b.DoWithAcceptable(failing, func(err error) bool {
+			return err == errTest
+		})
+		if err != errTest {
+			t.Fatalf("expected the acceptable errors not counted as failures, got %v", err)
+		}
+	}
+}
+
+func TestBreakerRecovers(t *testing.T) {
+	b := NewBreaker("test", Conf{
+		Window:  100 * time.Millisecond,
+		Buckets: 10,
+	})
+	for i := 0; i < 100; i++ {
+		_ = b.Do(failing)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("expected the breaker open, got %v", b.State())
+	}
+
+	// the failures expire with the window
+	time.Sleep(200 * time.Millisecond)
+	if err := b.Do(func() error {
+		return nil
+	}); err != nil {
+		t.Errorf("expected the request accepted after the window, got %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("expected the breaker closed, got %v", b.State())
+	}
+}
+
+func TestBreakerTinyWindow(t *testing.T) {
+	// the window shorter than the buckets in nanoseconds, like "Window": 10 in json
+	b := NewBreaker("test", Conf{
+		Window:  10,
+		Buckets: 40,
+	})
+	for i := 0; i < 100; i++ {
+		_ = b.Do(failing)
+	}
+}
+
+func failing() error {
+	return errTest
+}
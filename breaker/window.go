@@ -0,0 +1,84 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	bucket struct {
+		accepts int64
+		total   int64
+	}
+
+	// rollingWindow counts the requests in the recent size * interval duration.
+	rollingWindow struct {
+		buckets  []bucket
+		interval time.Duration
+		offset   int
+		lastTime time.Time
+		lock     sync.Mutex
+	}
+)
+
+// newRollingWindow returns a rollingWindow with size buckets of interval each,
+// at least 1 bucket of 1ns, so that the offset can always be computed.
+func newRollingWindow(size int, interval time.Duration) *rollingWindow {
+	if size <= 0 {
+		size = 1
+	}
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+
+	return &rollingWindow{
+		buckets:  make([]bucket, size),
+		interval: interval,
+		lastTime: time.Now(),
+	}
+}
+
+func (rw *rollingWindow) add(accepted bool) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	rw.updateOffset()
+	b := &rw.buckets[rw.offset]
+	b.total++
+	if accepted {
+		b.accepts++
+	}
+}
+
+func (rw *rollingWindow) reduce() (accepts, total int64) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	rw.updateOffset()
+	for _, b := range rw.buckets {
+		accepts += b.accepts
+		total += b.total
+	}
+
+	return
+}
+
+// updateOffset moves the offset to the current bucket, and resets the expired buckets.
+func (rw *rollingWindow) updateOffset() {
+	span := int(time.Since(rw.lastTime) / rw.interval)
+	if span <= 0 {
+		return
+	}
+
+	if span > len(rw.buckets) {
+		span = len(rw.buckets)
+	}
+	for i := 1; i <= span; i++ {
+		rw.buckets[(rw.offset+i)%len(rw.buckets)] = bucket{}
+	}
+
+	rw.offset = (rw.offset + span) % len(rw.buckets)
+	// align to the bucket boundary
+	now := time.Now()
+	rw.lastTime = now.Add(-(now.Sub(rw.lastTime) % rw.interval))
+}
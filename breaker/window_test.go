@@ -0,0 +1,47 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollingWindowAdd(t *testing.T) {
+	rw := newRollingWindow(10, time.Hour)
+	rw.add(true)
+	rw.add(true)
+	rw.add(false)
+
+	if accepts, total := rw.reduce(); accepts != 2 || total != 3 {
+		t.Errorf("expected 2 accepts of 3, got %d of %d", accepts, total)
+	}
+}
+
+func TestRollingWindowExpire(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	rw := newRollingWindow(3, interval)
+	rw.add(true)
+	time.Sleep(interval)
+	rw.add(false)
+
+	if accepts, total := rw.reduce(); accepts != 1 || total != 2 {
+		t.Errorf("expected 1 accept of 2 in the window, got %d of %d", accepts, total)
+	}
+
+	// the whole window passed, all the buckets expired
+	time.Sleep(4 * interval)
+	if accepts, total := rw.reduce(); accepts != 0 || total != 0 {
+		t.Errorf("expected the buckets expired, got %d of %d", accepts, total)
+	}
+}
+
+func TestRollingWindowZeroInterval(t *testing.T) {
+	for _, rw := range []*rollingWindow{
+		newRollingWindow(10, 0),
+		newRollingWindow(0, time.Second),
+	} {
+		rw.add(true)
+		if _, total := rw.reduce(); total > 1 {
+			t.Errorf("expected at most 1 request in the window, got %d", total)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"redis-cache/hash"
+	"sort"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+type (
+	// inspectable is implemented by the caches that the admin handler can inspect.
+	inspectable interface {
+		locate(key string) (cacheNode, bool)
+		delNodes(key string) []cacheNode
+		ringMembers() []member
+		cacheStat() *CacheStat
+	}
+
+	adminHandler struct {
+		cache inspectable
+		mux   *http.ServeMux
+	}
+
+	nodeInfo struct {
+		Node    string `json:"node"`
+		Type    string `json:"type"`
+		Weight  int    `json:"weight"`
+		Ejected bool   `json:"ejected"`
+	}
+
+	keyInfo struct {
+		Key         string          `json:"key"`
+		Node        string          `json:"node"`
+		Exists      bool            `json:"exists"`
+		Ttl         int             `json:"ttl"`
+		Placeholder bool            `json:"placeholder"`
+		Raw         string          `json:"raw,omitempty"`
+		Value       json.RawMessage `json:"value,omitempty"`
+	}
+
+	resourceInfo struct {
+		Kind  string         `json:"kind"`
+		Key   string         `json:"key"`
+		Stats *rdb.PoolStats `json:"stats,omitempty"`
+	}
+)
+
+// NewAdminHandler returns an http.Handler to inspect and operate c, it's meant to be mounted
+// on a debug port, with http.StripPrefix if mounted under a sub path. The routes are:
+//
+//	GET    /locate?key=    the node that owns the key
+//	GET    /key?key=       the raw value, the decoded value and the ttl of the key
+//	DELETE /key?key=       deletes the key from the nodes that DelCache deletes it from
+//	GET    /stats          the counters of the current stat interval
+//	GET    /ring           the ring members with their weights
+//	GET    /resources      the redis connections and their pool stats
+func NewAdminHandler(c Cache) http.Handler {
+	h := &adminHandler{
+		mux: http.NewServeMux(),
+	}
+	if ic, ok := unwrap(c).(inspectable); ok {
+		h.cache = ic
+	}
+
+	h.mux.HandleFunc("/locate", h.handleLocate)
+	h.mux.HandleFunc("/key", h.handleKey)
+	h.mux.HandleFunc("/stats", h.handleStats)
+	h.mux.HandleFunc("/ring", h.handleRing)
+	h.mux.HandleFunc("/resources", h.handleResources)
+
+	return h
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cache == nil {
+		http.Error(w, "cache is not inspectable", http.StatusNotImplemented)
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *adminHandler) handleLocate(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if len(key) == 0 {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := h.cache.locate(key)
+	if !ok {
+		http.Error(w, "no cache nodes", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJson(w, map[string]string{
+		"key":  key,
+		"node": node.String(),
+	})
+}
+
+func (h *adminHandler) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if len(key) == 0 {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	node, ok := h.cache.locate(key)
+	if !ok {
+		http.Error(w, "no cache nodes", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, err := inspectKey(r.Context(), node, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		writeJson(w, info)
+	case http.MethodDelete:
+		// the replicas, and the previous owner in the transition window, are deleted as well
+		nodes := h.cache.delNodes(key)
+		names := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			if err := n.rds.Del(r.Context(), key); err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			names = append(names, n.String())
+		}
+
+		writeJson(w, map[string]interface{}{
+			"key":   key,
+			"node":  node.String(),
+			"nodes": names,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *adminHandler) handleStats(w http.ResponseWriter, r *http.Request) {
+	st := h.cache.cacheStat()
+	if st == nil {
+		http.Error(w, "no cache stat", http.StatusNotFound)
+		return
+	}
+
+	writeJson(w, st.Snapshot())
+}
+
+func (h *adminHandler) handleRing(w http.ResponseWriter, r *http.Request) {
+	var nodes []nodeInfo
+	for _, m := range h.cache.ringMembers() {
+		nodes = append(nodes, nodeInfo{
+			Node:    m.node.String(),
+			Type:    m.node.rds.Type,
+			Weight:  m.weight,
+			Ejected: m.ejected,
+		})
+	}
+
+	writeJson(w, nodes)
+}
+
+func (h *adminHandler) handleResources(w http.ResponseWriter, r *http.Request) {
+	resources := make([]resourceInfo, 0)
+	for kind, manager := range map[string]*ResourceManager{
+		NodeType:       clientManager,
+		ClusterType:    clusterManager,
+		SentinelType:   sentinelManager,
+		"subscription": subscriptionManager,
+	} {
+		for key, resource := range manager.Resources() {
+			info := resourceInfo{
+				Kind: kind,
+				Key:  key,
+			}
+			if ps, ok := resource.(interface{ PoolStats() *rdb.PoolStats }); ok {
+				info.Stats = ps.PoolStats()
+			}
+			resources = append(resources, info)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].Key < resources[j].Key
+	})
+
+	writeJson(w, resources)
+}
+
+func (c cacheNode) locate(string) (cacheNode, bool) {
+	return c, true
+}
+
+func (c cacheNode) delNodes(string) []cacheNode {
+	return []cacheNode{c}
+}
+
+func (c cacheNode) ringMembers() []member {
+	return []member{
+		{
+			node:   c,
+			weight: hash.TopWeight,
+		},
+	}
+}
+
+func (c cacheNode) cacheStat() *CacheStat {
+	return c.stat
+}
+
+func (cc *cacheCluster) locate(key string) (cacheNode, bool) {
+	c, ok := cc.dispatcher.Get(cc.dispatchKey(key))
+	if !ok {
+		return cacheNode{}, false
+	}
+
+	return c.(cacheNode), true
+}
+
+func (cc *cacheCluster) delNodes(key string) []cacheNode {
+	targets, ok := cc.delTargets(key)
+	if !ok {
+		return nil
+	}
+
+	nodes := make([]cacheNode, len(targets))
+	for i, c := range targets {
+		nodes[i] = c.(cacheNode)
+	}
+
+	return nodes
+}
+
+func (cc *cacheCluster) ringMembers() []member {
+	cc.lock.RLock()
+	defer cc.lock.RUnlock()
+
+	members := make([]member, 0, len(cc.members))
+	for _, m := range cc.members {
+		members = append(members, *m)
+	}
+
+	return members
+}
+
+func (cc *cacheCluster) cacheStat() *CacheStat {
+	return cc.stat
+}
+
+func inspectKey(ctx context.Context, node cacheNode, key string) (keyInfo, error) {
+	info := keyInfo{
+		Key:  key,
+		Node: node.String(),
+	}
+
+	ttl, err := node.rds.Ttl(ctx, key)
+	if err != nil {
+		return info, err
+	}
+	info.Ttl = ttl
+	// -2 means the key doesn't exist
+	if ttl == -2 {
+		return info, nil
+	}
+
+	data, err := node.rds.Get(ctx, key)
+	if err != nil {
+		return info, err
+	}
+
+	info.Exists = true
+	info.Raw = data
+	if data == notFoundPlaceholder {
+		info.Placeholder = true
+	} else if json.Valid([]byte(data)) {
+		info.Value = json.RawMessage(data)
+	}
+
+	return info, nil
+}
+
+func unwrap(c Cache) Cache {
+	for {
+		switch ic := c.(type) {
+		case interceptedCluster:
+			return ic.cluster
+		case interceptedCache:
+			c = ic.cache
+		default:
+			return c
+		}
+	}
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,86 @@
+package cache
+
+import "redis-cache/utils"
+
+// track reports the in-flight request on the node to the bounded-load balancer,
+// the returned func must be called once the request is done.
+func (cc *cacheCluster) track(c Cache) func() {
+	if cc.loadBalancer == nil {
+		return func() {}
+	}
+
+	cc.loadBalancer.Inc(c)
+	return func() {
+		cc.loadBalancer.Done(c)
+	}
+}
+
+// delTargets returns the nodes to delete the key from, the owners of the key, or all the nodes
+// on the ring in the bounded-load mode, because the key might be dispatched to any of them,
+// followed by the previous owner in the transition window, to prevent the stale value from being read back.
+func (cc *cacheCluster) delTargets(key string) ([]Cache, bool) {
+	targets, ok := cc.ownersOrAll(key)
+	if !ok {
+		return nil, false
+	}
+
+	prev, ok := cc.previousOwner(key, targets[0])
+	if !ok {
+		return targets, true
+	}
+
+	prevRepr := utils.Repr(prev)
+	for _, c := range targets {
+		if utils.Repr(c) == prevRepr {
+			return targets, true
+		}
+	}
+
+	return append(targets, prev), true
+}
+
+// delStale deletes the key from the nodes other than the owners in the bounded-load mode,
+// the key might be cached on them when their owners were overloaded, and would be read back
+// once the loads change, the stale copies in the transition window are deleted as well.
+func (cc *cacheCluster) delStale(key string, owners []Cache) error {
+	if cc.loadBalancer == nil {
+		return nil
+	}
+
+	targets, ok := cc.delTargets(key)
+	if !ok {
+		return nil
+	}
+
+	written := make(map[string]bool, len(owners))
+	for _, c := range owners {
+		written[utils.Repr(c)] = true
+	}
+
+	var be utils.BatchError
+	for _, c := range targets {
+		if !written[utils.Repr(c)] {
+			be.Add(c.DelCache(key))
+		}
+	}
+
+	return be.Err()
+}
+
+func (cc *cacheCluster) ownersOrAll(key string) ([]Cache, bool) {
+	if cc.loadBalancer == nil {
+		return cc.owners(key)
+	}
+
+	cc.lock.RLock()
+	defer cc.lock.RUnlock()
+
+	nodes := make([]Cache, 0, len(cc.members))
+	for _, m := range cc.members {
+		if !m.ejected {
+			nodes = append(nodes, m.node)
+		}
+	}
+
+	return nodes, len(nodes) > 0
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestSetCacheUnderLoad(t *testing.T) {
+	var hosts []string
+	for i := 0; i < 3; i++ {
+		r, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		hosts = append(hosts, r.Addr())
+	}
+	cc := newTestCluster(hosts, WithBoundedLoad(0.25))
+	defer cc.Close()
+
+	const key = "key"
+	owners, ok := cc.owners(key)
+	if !ok {
+		t.Fatal("expected the owner of the key")
+	}
+	owner := owners[0]
+	load := func(n int) {
+		for i := 0; i < n; i++ {
+			cc.loadBalancer.Inc(owner)
+		}
+	}
+	unload := func(n int) {
+		for i := 0; i < n; i++ {
+			cc.loadBalancer.Done(owner)
+		}
+	}
+
+	// the key is written to the next node while its owner is overloaded
+	load(10)
+	if err := cc.SetCache(key, "stale"); err != nil {
+		t.Fatal(err)
+	}
+	unload(10)
+
+	// the owner takes the key back, the copy on the next node is deleted
+	if err := cc.SetCache(key, "fresh"); err != nil {
+		t.Fatal(err)
+	}
+	var val string
+	if err := cc.GetCache(key, &val); err != nil || val != "fresh" {
+		t.Fatalf("expected the fresh value, got %q, %v", val, err)
+	}
+
+	load(10)
+	defer unload(10)
+	val = ""
+	if err := cc.GetCache(key, &val); err != errTestNotFound {
+		t.Fatalf("expected the stale copy deleted, got %q, %v", val, err)
+	}
+}
@@ -3,9 +3,12 @@ package cache
 import (
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"redis-cache/hash"
+	"redis-cache/logx"
 	"redis-cache/singleflight"
 	"redis-cache/utils"
 )
@@ -20,9 +23,45 @@ type (
 		TakeWithExpire(v interface{}, key string, query func(v interface{}, expire time.Duration) error) error
 	}
 
+	// ClusterCache is a Cache on a cluster of nodes, which can be added, removed and reweighted at runtime.
+	ClusterCache interface {
+		Cache
+		// AddNode adds the node into the cluster.
+		AddNode(c NodeConf) error
+		// RemoveNode removes the node from the cluster, and closes its connections unless shared with the others.
+		// The node is identified by NodeConf.String(), which is the host for the node type.
+		RemoveNode(host string) error
+		// SetWeight changes the weight of the node.
+		SetWeight(host string, weight int) error
+		// Redis returns the redis of the node that owns the key.
+		Redis(key string) (*Redis, bool)
+		// Close stops the background goroutines of the cluster, like the health checker.
+		// The connections are shared with the other caches on the same nodes, so they are left open.
+		Close() error
+	}
+
 	cacheCluster struct {
-		dispatcher  *hash.ConsistentHash
+		dispatcher  hash.Balancer
+		newBalancer hash.BalancerFactory
+		members     []*member
 		errNotFound error
+		stat        *CacheStat
+		logger      logx.Logger
+		newNode     func(node NodeConf) cacheNode
+		// guards the members and their states
+		lock             sync.RWMutex
+		transitionWindow time.Duration
+		transition       atomic.Value
+		replicas         int
+		loadBalancer     hash.LoadBalancer
+		keyExtractor     KeyExtractor
+		checker          *healthChecker
+	}
+
+	member struct {
+		node    cacheNode
+		weight  int
+		ejected bool
 	}
 )
 
@@ -39,57 +78,134 @@ func totalWeights(c []NodeConf) int {
 	return weights
 }
 
+// NewCache returns a ClusterCache that dispatches the keys to the nodes by consistent hash,
+// the nodes can be changed at runtime.
 func NewCache(c ClusterConf, barrier singleflight.SharedCalls, st *CacheStat, errNotFound error,
-	opts ...Option) Cache {
+	opts ...Option) ClusterCache {
+	c = mergeClusters(c)
 	if len(c) == 0 || totalWeights(c) <= 0 {
 		log.Fatal("no cache nodes")
 	}
+	for _, node := range c {
+		if err := node.validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// 只有一个节点时，使用 cachenode
-	if len(c) == 1 {
-		return NewCacheNode(c[0].NewRedis(), barrier, st, errNotFound, opts...)
+	// 使用一致性 hash，即使只有一个节点，也需要支持节点的扩缩容
+	o := newOptions(opts...)
+	newBalancer, err := hash.NewBalancerFactory(o.Balancer)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// 使用一致性 hash
-	dispatcher := hash.NewConsistentHash()
-	for _, node := range c {
-		cn := NewCacheNode(node.NewRedis(), barrier, st, errNotFound, opts...)
-		dispatcher.AddWithWeight(cn, node.Weight)
+	cc := &cacheCluster{
+		newBalancer:      newBalancer,
+		errNotFound:      errNotFound,
+		stat:             st,
+		logger:           o.Logger,
+		transitionWindow: o.TransitionWindow,
+		replicas:         o.Replicas,
+		keyExtractor:     o.KeyExtractor,
+		newNode: func(node NodeConf) cacheNode {
+			rds := node.NewRedis()
+			rds.acquire()
+			return newCacheNode(rds, barrier, st, errNotFound, o)
+		},
+	}
+	cc.dispatcher = newBalancer()
+	addNodes(cc.dispatcher, c, func(node NodeConf) interface{} {
+		cn := cc.newNode(node)
+		cc.members = append(cc.members, &member{
+			node:   cn,
+			weight: node.Weight,
+		})
+		return cn
+	})
+	if o.LoadBound > 0 {
+		if o.KeyExtractor != nil {
+			log.Fatal("bounded loads can't be used with the key extractor, the keys wouldn't be co-located")
+		}
+
+		lb, ok := cc.dispatcher.(hash.LoadBalancer)
+		if !ok {
+			log.Fatalf("balancer %q doesn't support bounded loads", o.Balancer)
+		}
+		lb.SetLoadBound(o.LoadBound)
+		cc.loadBalancer = lb
+	}
+	if o.HealthCheck != nil {
+		// 跳跃一致性 hash 摘除中间的节点会迁移其后所有节点的 key
+		if o.Balancer == hash.JumpBalancer {
+			log.Fatal("jump balancer can't be used with the health check, ejecting a node would move most of the keys")
+		}
+
+		cc.checker = newHealthChecker(cc, *o.HealthCheck)
+		go cc.checker.run()
+	}
+
+	// 拦截器只作用在集群上，不重复作用在各个节点上
+	if len(o.Interceptors) == 0 {
+		return cc
 	}
 
-	return cacheCluster{
-		dispatcher:  dispatcher,
-		errNotFound: errNotFound,
+	return interceptedCluster{
+		Cache:   Intercept(cc, o.Interceptors...),
+		cluster: cc,
+	}
+}
+
+func (cc *cacheCluster) Close() error {
+	if cc.checker != nil {
+		cc.checker.stop()
 	}
+
+	return nil
 }
 
-func (cc cacheCluster) DelCache(keys ...string) error {
+func (cc *cacheCluster) DelCache(keys ...string) error {
 	switch len(keys) {
 	case 0:
 		return nil
 	case 1:
 		key := keys[0]
-		// 获取 cachenode
-		c, ok := cc.dispatcher.Get(key)
+		// 获取 key 所在的全部副本节点，迁移期间包括旧节点，防止旧数据被读回来
+		owners, ok := cc.delTargets(key)
 		if !ok {
 			return cc.errNotFound
 		}
+		if len(owners) == 1 {
+			return owners[0].DelCache(key)
+		}
 
-		return c.(Cache).DelCache(key)
+		var be utils.BatchError
+		for _, c := range owners {
+			be.Add(c.DelCache(key))
+		}
+		return be.Err()
 	default:
+		// cacheNode has func fields and can't be a map key, so group the keys by the node names
 		var be utils.BatchError
-		nodes := make(map[interface{}][]string)
+		nodes := make(map[string]Cache)
+		nodeKeys := make(map[string][]string)
+		add := func(c Cache, key string) {
+			name := utils.Repr(c)
+			nodes[name] = c
+			nodeKeys[name] = append(nodeKeys[name], key)
+		}
 		for _, key := range keys {
-			c, ok := cc.dispatcher.Get(key)
+			owners, ok := cc.delTargets(key)
 			if !ok {
 				be.Add(fmt.Errorf("key %q not found", key))
 				continue
 			}
 
-			nodes[c] = append(nodes[c], key)
+			for _, c := range owners {
+				add(c, key)
+			}
 		}
-		for c, ks := range nodes {
-			if err := c.(Cache).DelCache(ks...); err != nil {
+		for name, ks := range nodeKeys {
+			if err := nodes[name].DelCache(ks...); err != nil {
 				be.Add(err)
 			}
 		}
@@ -98,48 +214,155 @@ func (cc cacheCluster) DelCache(keys ...string) error {
 	}
 }
 
-func (cc cacheCluster) GetCache(key string, v interface{}) error {
-	c, ok := cc.dispatcher.Get(key)
+func (cc *cacheCluster) GetCache(key string, v interface{}) error {
+	owners, ok := cc.owners(key)
 	if !ok {
 		return cc.errNotFound
 	}
+	defer cc.track(owners[0])()
 
-	return c.(Cache).GetCache(key, v)
+	err := cc.tryOwners(owners, nil, func(c Cache) error {
+		return c.GetCache(key, v)
+	})
+	if err != cc.errNotFound {
+		return err
+	}
+
+	// 迁移期间新节点未命中时，从旧节点读取，并复制到新节点
+	c := owners[0]
+	prev, ok := cc.previousOwner(key, c)
+	if !ok || prev.GetCache(key, v) != nil {
+		return err
+	}
+
+	if e := c.SetCache(key, v); e != nil {
+		cc.log().Error("failed to copy cache from previous node", logx.Node(utils.Repr(c)), logx.Key(key), logx.Err(e))
+	}
+
+	return nil
 }
 
-func (cc cacheCluster) SetCache(key string, v interface{}) error {
-	c, ok := cc.dispatcher.Get(key)
+func (cc *cacheCluster) SetCache(key string, v interface{}) error {
+	owners, ok := cc.owners(key)
 	if !ok {
 		return cc.errNotFound
 	}
+	defer cc.track(owners[0])()
+	if len(owners) == 1 && cc.loadBalancer == nil {
+		return owners[0].SetCache(key, v)
+	}
 
-	return c.(Cache).SetCache(key, v)
+	var be utils.BatchError
+	for _, c := range owners {
+		be.Add(c.SetCache(key, v))
+	}
+	be.Add(cc.delStale(key, owners))
+	return be.Err()
 }
 
-func (cc cacheCluster) SetCacheWithExpire(key string, v interface{}, expire time.Duration) error {
-	c, ok := cc.dispatcher.Get(key)
+func (cc *cacheCluster) SetCacheWithExpire(key string, v interface{}, expire time.Duration) error {
+	owners, ok := cc.owners(key)
 	if !ok {
 		return cc.errNotFound
 	}
+	defer cc.track(owners[0])()
+	if len(owners) == 1 && cc.loadBalancer == nil {
+		return owners[0].SetCacheWithExpire(key, v, expire)
+	}
 
-	return c.(Cache).SetCacheWithExpire(key, v, expire)
+	var be utils.BatchError
+	for _, c := range owners {
+		be.Add(c.SetCacheWithExpire(key, v, expire))
+	}
+	be.Add(cc.delStale(key, owners))
+	return be.Err()
 }
 
-func (cc cacheCluster) Take(v interface{}, key string, query func(v interface{}) error) error {
-	c, ok := cc.dispatcher.Get(key)
+func (cc *cacheCluster) Take(v interface{}, key string, query func(v interface{}) error) error {
+	owners, ok := cc.owners(key)
 	if !ok {
 		return cc.errNotFound
 	}
+	defer cc.track(owners[0])()
+
+	// 迁移期间新节点未命中时，先从旧节点读取，读到的数据由新节点缓存
+	if prev, ok := cc.previousOwner(key, owners[0]); ok {
+		q := query
+		query = func(v interface{}) error {
+			if err := prev.GetCache(key, v); err == nil {
+				return nil
+			}
+
+			return q(v)
+		}
+	}
+	if len(owners) == 1 {
+		return owners[0].Take(v, key, query)
+	}
 
-	return c.(Cache).Take(v, key, query)
+	// 新加载的数据由加载的节点缓存，再复制到其他副本
+	var loaded bool
+	var taker Cache
+	isLoaded := func() bool {
+		return loaded
+	}
+	err := cc.tryOwners(owners, isLoaded, func(c Cache) error {
+		taker = c
+		return c.Take(v, key, func(v interface{}) error {
+			loaded = true
+			return query(v)
+		})
+	})
+	if err == nil && loaded {
+		cc.replicate(owners, taker, key, func(c Cache) error {
+			return c.SetCache(key, v)
+		})
+	}
+
+	return err
 }
 
-func (cc cacheCluster) TakeWithExpire(v interface{}, key string,
+func (cc *cacheCluster) TakeWithExpire(v interface{}, key string,
 	query func(v interface{}, expire time.Duration) error) error {
-	c, ok := cc.dispatcher.Get(key)
+	owners, ok := cc.owners(key)
 	if !ok {
 		return cc.errNotFound
 	}
+	defer cc.track(owners[0])()
+
+	if prev, ok := cc.previousOwner(key, owners[0]); ok {
+		q := query
+		query = func(v interface{}, expire time.Duration) error {
+			if err := prev.GetCache(key, v); err == nil {
+				return nil
+			}
+
+			return q(v, expire)
+		}
+	}
+	if len(owners) == 1 {
+		return owners[0].TakeWithExpire(v, key, query)
+	}
+
+	var loaded bool
+	var taker Cache
+	var expiry time.Duration
+	isLoaded := func() bool {
+		return loaded
+	}
+	err := cc.tryOwners(owners, isLoaded, func(c Cache) error {
+		taker = c
+		return c.TakeWithExpire(v, key, func(v interface{}, expire time.Duration) error {
+			loaded = true
+			expiry = expire
+			return query(v, expire)
+		})
+	})
+	if err == nil && loaded {
+		cc.replicate(owners, taker, key, func(c Cache) error {
+			return c.SetCacheWithExpire(key, v, expiry)
+		})
+	}
 
-	return c.(Cache).TakeWithExpire(v, key, query)
+	return err
 }
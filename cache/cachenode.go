@@ -4,17 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
 	"math/rand"
+	"redis-cache/breaker"
+	"redis-cache/logx"
 	"redis-cache/singleflight"
-	"redis-cache/utils"
 	"sync"
 	"time"
 )
 
 const (
 	notFoundPlaceholder = "*"
+	// the corrupt values are copied to the keys with this prefix in quarantine mode
+	quarantinePrefix = "quarantine#"
 	// make the expiry unstable to avoid lots of cached items expire at the same time
 	// make the unstable expiry to be [0.95, 1.05] * seconds
 	expiryDeviation = 0.05
@@ -23,6 +24,28 @@ const (
 // indicates there is no such value associate with the key
 var errPlaceholder = errors.New("placeholder")
 
+type (
+	// Corruption describes a cached value that can't be unmarshaled.
+	Corruption struct {
+		Node  string
+		Key   string
+		Value string
+		Err   error
+	}
+
+	// CorruptionHandler is called on every corrupt cached value, before it's deleted.
+	CorruptionHandler func(corruption Corruption)
+
+	// FallbackPolicy decides whether Take queries the loader directly, if the cache fails with err.
+	// The loaded values are not cached in this case.
+	FallbackPolicy func(key string, err error) bool
+)
+
+// FallbackOnBreakerOpen queries the loader directly while the breaker of the redis node is open.
+func FallbackOnBreakerOpen(key string, err error) bool {
+	return err == breaker.ErrServiceUnavailable
+}
+
 type cacheNode struct {
 	rds            *Redis
 	expiry         time.Duration
@@ -33,12 +56,27 @@ type cacheNode struct {
 	unstableExpiry Unstable
 	stat           *CacheStat
 	errNotFound    error
+	logger         logx.Logger
+	onCorruption   CorruptionHandler
+	quarantine     time.Duration
+	fallback       FallbackPolicy
+	degrader       *Degrader
 	Ctx            context.Context
 }
 
 func NewCacheNode(rds *Redis, barrier singleflight.SharedCalls, st *CacheStat,
 	errNotFound error, opts ...Option) Cache {
 	o := newOptions(opts...)
+	return Intercept(newCacheNode(rds, barrier, st, errNotFound, o), o.Interceptors...)
+}
+
+func newCacheNode(rds *Redis, barrier singleflight.SharedCalls, st *CacheStat, errNotFound error,
+	o Options) cacheNode {
+	rds.AddBreakerListener(func(name string, from, to breaker.State) {
+		if to == breaker.StateOpen {
+			st.IncrementBreakerTrips()
+		}
+	})
 
 	return cacheNode{
 		rds:            rds,
@@ -50,6 +88,11 @@ func NewCacheNode(rds *Redis, barrier singleflight.SharedCalls, st *CacheStat,
 		unstableExpiry: NewUnstable(expiryDeviation), //
 		stat:           st,
 		errNotFound:    errNotFound,
+		logger:         o.Logger,
+		onCorruption:   o.CorruptionHandler,
+		quarantine:     o.QuarantineExpiry,
+		fallback:       o.FallbackPolicy,
+		degrader:       o.Degrader,
 		Ctx:            context.Background(),
 	}
 }
@@ -60,7 +103,8 @@ func (c cacheNode) DelCache(keys ...string) error {
 	}
 
 	if err := c.rds.Del(c.Ctx, keys...); err != nil {
-		log.Printf("failed to clear cache with keys: %q, error: %v", utils.FormatKeys(keys), err)
+		c.countRejects(err)
+		c.log().Error("failed to clear cache", logx.Node(c.rds.Addr), logx.Keys(keys), logx.Err(err))
 	}
 
 	return nil
@@ -84,11 +128,14 @@ func (c cacheNode) SetCacheWithExpire(key string, v interface{}, expire time.Dur
 		return err
 	}
 
-	return c.rds.Set(c.Ctx, key, string(data), expire)
+	err = c.rds.Set(c.Ctx, key, string(data), expire)
+	c.countRejects(err)
+
+	return err
 }
 
 func (c cacheNode) String() string {
-	return c.rds.Addr
+	return c.rds.String()
 }
 
 func (c cacheNode) Take(v interface{}, key string, query func(v interface{}) error) error {
@@ -117,6 +164,7 @@ func (c cacheNode) doGetCache(key string, v interface{}) error {
 	data, err := c.rds.Get(c.Ctx, key)
 	if err != nil {
 		c.stat.IncrementMiss()
+		c.countRejects(err)
 		return err
 	}
 
@@ -142,14 +190,18 @@ func (c cacheNode) doTake(v interface{}, key string, query func(v interface{}) e
 				return nil, c.errNotFound
 			} else if err != c.errNotFound {
 				// 如果是未知错误，那么就直接返回，因为我们不能放弃缓存出错而直接把所有请求去请求DB，
-				// 这样在高并发的场景下会把DB打挂掉的
+				// 这样在高并发的场景下会把DB打挂掉的，除非降级策略允许直接请求DB
+				if c.shouldFallback(key, err) {
+					return c.doFallback(v, query)
+				}
+
 				return nil, err
 			}
 			// 从 db 里获取数据
 			if err = query(v); err == c.errNotFound {
 				// 设置 Placeholder 防止缓存穿透
 				if err = c.setCacheWithNotFound(key); err != nil {
-					log.Println(err)
+					c.log().Error("failed to set placeholder", logx.Node(c.rds.Addr), logx.Key(key), logx.Err(err))
 				}
 
 				return nil, c.errNotFound
@@ -160,7 +212,7 @@ func (c cacheNode) doTake(v interface{}, key string, query func(v interface{}) e
 
 			// 缓存数据
 			if err = cacheVal(v); err != nil {
-				log.Println(err)
+				c.log().Error("failed to set cache", logx.Node(c.rds.Addr), logx.Key(key), logx.Err(err))
 			}
 		}
 
@@ -186,20 +238,78 @@ func (c cacheNode) processCache(key string, data string, v interface{}) error {
 		return nil
 	}
 
-	report := fmt.Sprintf("unmarshal cache, node: %s, key: %s, value: %s, error: %v",
-		c.rds.Addr, key, data, err)
-	log.Println(report)
+	c.stat.IncrementCorruptions()
+	c.log().Error("failed to unmarshal cache", logx.Node(c.rds.Addr), logx.Key(key),
+		logx.Any("value", data), logx.Err(err))
 	// 上报错误 cache
-	// stat.Report(report)
+	if c.onCorruption != nil {
+		c.onCorruption(Corruption{
+			Node:  c.rds.Addr,
+			Key:   key,
+			Value: data,
+			Err:   err,
+		})
+	}
+	// 隔离模式下，删除前把错误的数据复制到隔离 key 上，便于排查
+	if c.quarantine > 0 {
+		if e := c.rds.Set(c.Ctx, quarantinePrefix+key, data, c.quarantine); e != nil {
+			c.log().Error("failed to quarantine invalid cache", logx.Node(c.rds.Addr), logx.Key(key),
+				logx.Err(e))
+		}
+	}
 	if e := c.rds.Del(c.Ctx, key); e != nil {
-		log.Printf("delete invalid cache, node: %s, key: %s, value: %s, error: %v",
-			c.rds.Addr, key, data, e)
+		c.log().Error("failed to delete invalid cache", logx.Node(c.rds.Addr), logx.Key(key),
+			logx.Any("value", data), logx.Err(e))
 	}
 
 	// returns errNotFound to reload the value by the given queryFn
 	return c.errNotFound
 }
 
+// doFallback queries the loader directly without caching, because the cache is unavailable.
+// The loader calls are limited by the degrader in degraded mode.
+func (c cacheNode) doFallback(v interface{}, query func(v interface{}) error) (interface{}, error) {
+	load := func() error {
+		err := query(v)
+		if err != nil && err != c.errNotFound {
+			c.stat.IncrementDbFails()
+		}
+		return err
+	}
+
+	var err error
+	if c.degrader != nil {
+		err = c.degrader.Do(load)
+	} else {
+		err = load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// shouldFallback decides whether to query the loader directly on the cache error,
+// in degraded mode all the errors do if no FallbackPolicy is set.
+func (c cacheNode) shouldFallback(key string, err error) bool {
+	if c.fallback != nil {
+		return c.fallback(key, err)
+	}
+
+	return c.degrader != nil
+}
+
+func (c cacheNode) countRejects(err error) {
+	if err == breaker.ErrServiceUnavailable {
+		c.stat.IncrementBreakerRejects()
+	}
+}
+
+func (c cacheNode) log() logx.Logger {
+	return logx.OrGlobal(c.logger)
+}
+
 // 没有的 key 缓存 Placeholder 防止缓存击穿
 func (c cacheNode) setCacheWithNotFound(key string) error {
 	return c.rds.Set(c.Ctx, key, notFoundPlaceholder, c.aroundDuration(c.notFoundExpiry))
@@ -1,20 +1,43 @@
 package cache
 
 import (
-	"log"
+	"fmt"
+	"redis-cache/logx"
 	"sync/atomic"
 	"time"
 )
 
 const statInterval = time.Minute
 
-type CacheStat struct {
-	name    string
-	Total   uint64
-	Hit     uint64
-	Miss    uint64
-	DbFails uint64
-}
+type (
+	CacheStat struct {
+		name           string
+		Total          uint64
+		Hit            uint64
+		Miss           uint64
+		DbFails        uint64
+		Corruptions    uint64
+		BreakerTrips   uint64
+		BreakerRejects uint64
+		Ejections      uint64
+		Rejoins        uint64
+	}
+
+	// StatSnapshot is the counters of the current stat interval.
+	StatSnapshot struct {
+		Name           string  `json:"name"`
+		Total          uint64  `json:"total"`
+		Hit            uint64  `json:"hit"`
+		Miss           uint64  `json:"miss"`
+		DbFails        uint64  `json:"dbFails"`
+		Corruptions    uint64  `json:"corruptions"`
+		BreakerTrips   uint64  `json:"breakerTrips"`
+		BreakerRejects uint64  `json:"breakerRejects"`
+		Ejections      uint64  `json:"ejections"`
+		Rejoins        uint64  `json:"rejoins"`
+		HitRatio       float32 `json:"hitRatio"`
+	}
+)
 
 func NewCacheStat(name string) *CacheStat {
 	ret := &CacheStat{
@@ -41,21 +64,85 @@ func (cs *CacheStat) IncrementDbFails() {
 	atomic.AddUint64(&cs.DbFails, 1)
 }
 
+// Snapshot returns the counters of the current stat interval, which are reset every statInterval.
+func (cs *CacheStat) Snapshot() StatSnapshot {
+	ss := StatSnapshot{
+		Name:           cs.name,
+		Total:          atomic.LoadUint64(&cs.Total),
+		Hit:            atomic.LoadUint64(&cs.Hit),
+		Miss:           atomic.LoadUint64(&cs.Miss),
+		DbFails:        atomic.LoadUint64(&cs.DbFails),
+		Corruptions:    atomic.LoadUint64(&cs.Corruptions),
+		BreakerTrips:   atomic.LoadUint64(&cs.BreakerTrips),
+		BreakerRejects: atomic.LoadUint64(&cs.BreakerRejects),
+		Ejections:      atomic.LoadUint64(&cs.Ejections),
+		Rejoins:        atomic.LoadUint64(&cs.Rejoins),
+	}
+	if ss.Total > 0 {
+		ss.HitRatio = 100 * float32(ss.Hit) / float32(ss.Total)
+	}
+
+	return ss
+}
+
+func (cs *CacheStat) IncrementCorruptions() {
+	atomic.AddUint64(&cs.Corruptions, 1)
+}
+
+// IncrementBreakerTrips counts the times that the breakers of the redis nodes open.
+func (cs *CacheStat) IncrementBreakerTrips() {
+	atomic.AddUint64(&cs.BreakerTrips, 1)
+}
+
+// IncrementBreakerRejects counts the calls that failed fast by the open breakers.
+func (cs *CacheStat) IncrementBreakerRejects() {
+	atomic.AddUint64(&cs.BreakerRejects, 1)
+}
+
+// IncrementEjections counts the nodes ejected from the ring by the health checker.
+func (cs *CacheStat) IncrementEjections() {
+	atomic.AddUint64(&cs.Ejections, 1)
+}
+
+// IncrementRejoins counts the nodes added back to the ring by the health checker.
+func (cs *CacheStat) IncrementRejoins() {
+	atomic.AddUint64(&cs.Rejoins, 1)
+}
+
 func (cs *CacheStat) statLoop() {
 	ticker := time.NewTicker(statInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		total := atomic.SwapUint64(&cs.Total, 0)
-		if total == 0 {
-			continue
-		}
+		cs.report()
+	}
+}
+
+// report logs and resets the counters of the interval, the hit ratio is only logged with requests,
+// but the events like ejections are always logged and reset, so that they are reported in their interval.
+func (cs *CacheStat) report() {
+	total := atomic.SwapUint64(&cs.Total, 0)
+	hit := atomic.SwapUint64(&cs.Hit, 0)
+	miss := atomic.SwapUint64(&cs.Miss, 0)
+	dbf := atomic.SwapUint64(&cs.DbFails, 0)
+	corruptions := atomic.SwapUint64(&cs.Corruptions, 0)
+	trips := atomic.SwapUint64(&cs.BreakerTrips, 0)
+	rejects := atomic.SwapUint64(&cs.BreakerRejects, 0)
+	ejections := atomic.SwapUint64(&cs.Ejections, 0)
+	rejoins := atomic.SwapUint64(&cs.Rejoins, 0)
+	events := []logx.Field{
+		logx.Any("db_fails", dbf), logx.Any("corruptions", corruptions),
+		logx.Any("breaker_trips", trips), logx.Any("breaker_rejects", rejects),
+		logx.Any("ejections", ejections), logx.Any("rejoins", rejoins),
+	}
 
-		hit := atomic.SwapUint64(&cs.Hit, 0)
+	if total > 0 {
 		percent := 100 * float32(hit) / float32(total)
-		miss := atomic.SwapUint64(&cs.Miss, 0)
-		dbf := atomic.SwapUint64(&cs.DbFails, 0)
-		log.Printf("dbcache(%s) - qpm: %d, hit_ratio: %.1f%%, hit: %d, miss: %d, db_fails: %d",
-			cs.name, total, percent, hit, miss, dbf)
+		logx.GetLogger().Info("cache stat", append([]logx.Field{logx.Any("name", cs.name), logx.Any("qpm", total),
+			logx.Any("hit_ratio", fmt.Sprintf("%.1f%%", percent)), logx.Any("hit", hit),
+			logx.Any("miss", miss)}, events...)...)
+	} else if dbf+corruptions+trips+rejects+ejections+rejoins > 0 {
+		logx.GetLogger().Info("cache stat", append([]logx.Field{logx.Any("name", cs.name),
+			logx.Any("qpm", total)}, events...)...)
 	}
 }
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+
+	"redis-cache/logx"
+)
+
+type recordLogger struct {
+	lock    sync.Mutex
+	entries []map[string]interface{}
+}
+
+func (l *recordLogger) Debug(msg string, fields ...logx.Field) {
+	l.record(fields)
+}
+
+func (l *recordLogger) Info(msg string, fields ...logx.Field) {
+	l.record(fields)
+}
+
+func (l *recordLogger) Warn(msg string, fields ...logx.Field) {
+	l.record(fields)
+}
+
+func (l *recordLogger) Error(msg string, fields ...logx.Field) {
+	l.record(fields)
+}
+
+func (l *recordLogger) record(fields []logx.Field) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entry := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		entry[field.Key] = field.Value
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func TestCacheStatReportIdleEvents(t *testing.T) {
+	logger := new(recordLogger)
+	logx.SetLogger(logger)
+	defer logx.SetLogger(logx.NewStdLogger(nil, logx.LevelInfo))
+
+	cs := &CacheStat{name: "test"}
+	cs.IncrementEjections()
+	cs.IncrementBreakerTrips()
+	cs.report()
+
+	if len(logger.entries) != 1 {
+		t.Fatalf("expected the events reported without requests, got %v", logger.entries)
+	}
+	entry := logger.entries[0]
+	if entry["ejections"] != uint64(1) || entry["breaker_trips"] != uint64(1) {
+		t.Errorf("expected 1 ejection and 1 breaker trip, got %v", entry)
+	}
+	if _, ok := entry["hit_ratio"]; ok {
+		t.Errorf("expected no hit ratio without requests, got %v", entry)
+	}
+
+	// the events are reset after reported, not carried into the next interval
+	cs.IncrementTotal()
+	cs.IncrementHit()
+	cs.report()
+	if len(logger.entries) != 2 {
+		t.Fatalf("expected the requests reported, got %v", logger.entries)
+	}
+	entry = logger.entries[1]
+	if entry["ejections"] != uint64(0) || entry["hit_ratio"] != "100.0%" {
+		t.Errorf("expected no ejections and 100%% hit ratio, got %v", entry)
+	}
+
+	// nothing to report
+	cs.report()
+	if len(logger.entries) != 2 {
+		t.Errorf("expected nothing reported in an idle interval, got %v", logger.entries[2:])
+	}
+}
@@ -1,17 +1,121 @@
 package cache
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"redis-cache/breaker"
+	"time"
+)
+
 type (
 	ClusterConf []NodeConf
 
+	// NodeConf is the config of a cache node, Host is the comma separated sentinel addresses
+	// for the sentinel type, and MasterName is required.
+	// Weight is relative to the other nodes without an upper bound, like 400 for a node with 4 times the memory.
 	NodeConf struct {
-		Host   string
-		Type   string `json:",default=node,options=node|cluster"`
-		Pass   string `json:",optional"`
-		Weight int    `json:",default=100"`
+		Host         string
+		Type         string        `json:",default=node,options=node|cluster|sentinel"`
+		Pass         string        `json:",optional"`
+		Weight       int           `json:",default=100"`
+		DB           int           `json:",optional"`
+		User         string        `json:",optional"`
+		Tls          TlsConf       `json:",optional"`
+		PoolSize     int           `json:",optional"`
+		MinIdleConns int           `json:",optional"`
+		DialTimeout  time.Duration `json:",optional"`
+		ReadTimeout  time.Duration `json:",optional"`
+		WriteTimeout time.Duration `json:",optional"`
+		MaxRetries   int           `json:",optional"`
+		MasterName   string        `json:",optional"`
+		SentinelPass string        `json:",optional"`
+		ReplicaReads bool          `json:",optional"`
+		Breaker      breaker.Conf  `json:",optional"`
 	}
 	CacheConf = ClusterConf
+
+	// TlsConf is the TLS settings to connect redis with.
+	TlsConf struct {
+		Enabled    bool   `json:",optional"`
+		CaFile     string `json:",optional"`
+		CertFile   string `json:",optional"`
+		KeyFile    string `json:",optional"`
+		SkipVerify bool   `json:",optional"`
+	}
 )
 
 func (rc NodeConf) NewRedis() *Redis {
-	return NewRedis(rc.Host, rc.Type, rc.Pass)
+	r := NewRedis(rc.Host, rc.Type, rc.Pass)
+	r.DB = rc.DB
+	r.User = rc.User
+	r.Tls = rc.Tls
+	r.PoolSize = rc.PoolSize
+	r.MinIdleConns = rc.MinIdleConns
+	r.DialTimeout = rc.DialTimeout
+	r.ReadTimeout = rc.ReadTimeout
+	r.WriteTimeout = rc.WriteTimeout
+	r.MaxRetries = rc.MaxRetries
+	r.MasterName = rc.MasterName
+	r.SentinelPass = rc.SentinelPass
+	r.ReplicaReads = rc.ReplicaReads
+	r.BreakerConf = rc.Breaker
+
+	return r
+}
+
+// validate checks the settings that can't work with the node type.
+func (rc NodeConf) validate() error {
+	if rc.Type == ClusterType && rc.DB != defaultDatabase {
+		return fmt.Errorf("cache node %q: %w", rc.String(), ErrClusterDB)
+	}
+	if rc.Type == SentinelType && rc.ReplicaReads && rc.DB != defaultDatabase {
+		return fmt.Errorf("cache node %q: %w", rc.String(), ErrReplicaReadsDB)
+	}
+
+	return nil
+}
+
+// String returns the identity of the node on the ring, it's the same as the one of its Redis.
+func (rc NodeConf) String() string {
+	return nodeName(rc.Type, rc.Host, rc.MasterName)
+}
+
+// TlsConfig returns the *tls.Config built from the settings, nil if TLS is not enabled.
+func (tc TlsConf) TlsConfig() (*tls.Config, error) {
+	if !tc.Enabled {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: tc.SkipVerify,
+	}
+
+	if len(tc.CaFile) > 0 {
+		pem, err := ioutil.ReadFile(tc.CaFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", tc.CaFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	switch {
+	case len(tc.CertFile) > 0 && len(tc.KeyFile) > 0:
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case len(tc.CertFile) > 0 || len(tc.KeyFile) > 0:
+		return nil, errors.New("both CertFile and KeyFile are required for the client certificate")
+	}
+
+	return cfg, nil
 }
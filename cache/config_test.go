@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNodeConfValidate(t *testing.T) {
+	for _, test := range []struct {
+		conf NodeConf
+		err  error
+	}{
+		{conf: NodeConf{Host: "a:6379", Type: NodeType, DB: 3}},
+		{conf: NodeConf{Host: "a:6379", Type: ClusterType}},
+		{conf: NodeConf{Host: "a:6379", Type: ClusterType, DB: 3}, err: ErrClusterDB},
+		{conf: NodeConf{Host: "a:26379", Type: SentinelType, MasterName: "m", DB: 3}},
+		{conf: NodeConf{Host: "a:26379", Type: SentinelType, MasterName: "m", ReplicaReads: true}},
+		{
+			conf: NodeConf{Host: "a:26379", Type: SentinelType, MasterName: "m", DB: 3, ReplicaReads: true},
+			err:  ErrReplicaReadsDB,
+		},
+	} {
+		if err := test.conf.validate(); !errors.Is(err, test.err) {
+			t.Errorf("expected %v for %+v, got %v", test.err, test.conf, err)
+		}
+	}
+}
+
+func TestNodeConfString(t *testing.T) {
+	for _, test := range []struct {
+		a, b NodeConf
+	}{
+		{
+			a: NodeConf{Host: "b:7000,a:7000", Type: ClusterType},
+			b: NodeConf{Host: "a:7000, b:7000,a:7000", Type: ClusterType},
+		},
+		{
+			a: NodeConf{Host: "b:26379,a:26379", Type: SentinelType, MasterName: "m"},
+			b: NodeConf{Host: "a:26379, b:26379", Type: SentinelType, MasterName: "m"},
+		},
+	} {
+		if test.a.String() != test.b.String() {
+			t.Errorf("expected the same identity, got %q and %q", test.a.String(), test.b.String())
+		}
+	}
+
+	if name := (NodeConf{Host: "b:26379,a:26379", Type: SentinelType, MasterName: "m"}).String(); name != "m@a:26379,b:26379" {
+		t.Errorf("expected m@a:26379,b:26379, got %q", name)
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"errors"
+	"redis-cache/limit"
+)
+
+// ErrDegradeRejected is returned when the loader call is rejected by the Degrader in degraded mode.
+var ErrDegradeRejected = errors.New("rejected by the degrader, cache is unavailable")
+
+type (
+	// DegradeConf is the limits of the loader calls in degraded mode, zero values mean no limit.
+	DegradeConf struct {
+		// MaxConcurrency is the maximum number of the concurrent loader calls.
+		MaxConcurrency int `json:",optional"`
+		// Rate is the loader calls allowed per second.
+		Rate float64 `json:",optional"`
+		// Burst is the maximum loader calls allowed in a burst, defaults to 1 if Rate is set.
+		Burst int `json:",optional"`
+	}
+
+	// Degrader limits the loader calls when the cache is failing, to keep partial availability
+	// without overloading the DB. Share one Degrader between the caches to limit the calls globally.
+	Degrader struct {
+		concurrency *limit.ConcurrencyLimiter
+		tokens      *limit.TokenLimiter
+	}
+)
+
+// NewDegrader returns a Degrader with the given limits.
+func NewDegrader(c DegradeConf) *Degrader {
+	var d Degrader
+	if c.MaxConcurrency > 0 {
+		d.concurrency = limit.NewConcurrencyLimiter(c.MaxConcurrency)
+	}
+	if c.Rate > 0 {
+		d.tokens = limit.NewTokenLimiter(c.Rate, c.Burst)
+	}
+
+	return &d
+}
+
+// Do calls fn if the limits allow, otherwise returns ErrDegradeRejected.
+func (d *Degrader) Do(fn func() error) error {
+	if d.tokens != nil && !d.tokens.Allow() {
+		return ErrDegradeRejected
+	}
+
+	if d.concurrency != nil {
+		if !d.concurrency.TryBorrow() {
+			return ErrDegradeRejected
+		}
+		defer d.concurrency.Return()
+	}
+
+	return fn()
+}
@@ -0,0 +1,48 @@
+package cache
+
+import "strings"
+
+// KeyExtractor extracts the part of the key that the key is dispatched to the nodes by.
+type KeyExtractor func(key string) string
+
+// HashTag returns the hash tag of the key like Redis Cluster, that is the substring between
+// the first { and the first } after it if not empty, otherwise the whole key.
+// For example, user:{1000}:profile and user:{1000}:settings are both dispatched by 1000.
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
+
+func (ic interceptedCluster) Redis(key string) (*Redis, bool) {
+	return ic.cluster.Redis(key)
+}
+
+// Redis returns the redis of the node that owns the key. With WithKeyExtractor(HashTag), the keys
+// with the same hash tag are on the same node, so they can be used together in MGET, pipelines,
+// scripts and transactions.
+func (cc *cacheCluster) Redis(key string) (*Redis, bool) {
+	c, ok := cc.locate(key)
+	if !ok {
+		return nil, false
+	}
+
+	return c.rds, true
+}
+
+// dispatchKey returns the part of the key to look up the dispatcher with.
+func (cc *cacheCluster) dispatchKey(key string) string {
+	if cc.keyExtractor == nil {
+		return key
+	}
+
+	return cc.keyExtractor(key)
+}
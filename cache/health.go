@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"context"
+	"redis-cache/logx"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = time.Second
+	defaultFailThreshold       = 3
+	defaultRecoverThreshold    = 5
+)
+
+type (
+	// HealthCheckConf is the config of the health checker, a node is ejected from the ring after
+	// FailThreshold consecutive failed pings, and added back after RecoverThreshold consecutive
+	// successful pings, the different thresholds prevent a flapping node from moving keys back and forth.
+	HealthCheckConf struct {
+		Interval         time.Duration `json:",default=1s"`
+		FailThreshold    int           `json:",default=3"`
+		RecoverThreshold int           `json:",default=5"`
+		// FlushOnRejoin flushes the database of the node before adding it back, because the keys
+		// updated while it was ejected are stale in it.
+		FlushOnRejoin bool `json:",optional"`
+	}
+
+	healthChecker struct {
+		cluster *cacheCluster
+		conf    HealthCheckConf
+		// consecutive failures and successes, only accessed by the checker goroutine
+		fails     map[*member]int
+		successes map[*member]int
+		done      chan struct{}
+		stopOnce  sync.Once
+	}
+)
+
+func newHealthChecker(cluster *cacheCluster, c HealthCheckConf) *healthChecker {
+	if c.Interval <= 0 {
+		c.Interval = defaultHealthCheckInterval
+	}
+	if c.FailThreshold <= 0 {
+		c.FailThreshold = defaultFailThreshold
+	}
+	if c.RecoverThreshold <= 0 {
+		c.RecoverThreshold = defaultRecoverThreshold
+	}
+
+	return &healthChecker{
+		cluster:   cluster,
+		conf:      c,
+		fails:     make(map[*member]int),
+		successes: make(map[*member]int),
+		done:      make(chan struct{}),
+	}
+}
+
+func (hc *healthChecker) run() {
+	ticker := time.NewTicker(hc.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hc.check()
+		case <-hc.done:
+			return
+		}
+	}
+}
+
+// stop stops the checker goroutine, it's safe to call more than once.
+func (hc *healthChecker) stop() {
+	hc.stopOnce.Do(func() {
+		close(hc.done)
+	})
+}
+
+func (hc *healthChecker) check() {
+	members := hc.cluster.allMembers()
+	errs := make([]error, len(members))
+
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), hc.conf.Interval)
+			defer cancel()
+			errs[i] = m.node.rds.Ping(ctx)
+		}(i, m)
+	}
+	wg.Wait()
+
+	hc.forgetRemoved(members)
+	for i, m := range members {
+		if errs[i] != nil {
+			hc.onFailure(m, errs[i])
+		} else {
+			hc.onSuccess(m)
+		}
+	}
+}
+
+// forgetRemoved forgets the counts of the members removed from the cluster.
+func (hc *healthChecker) forgetRemoved(members []*member) {
+	current := make(map[*member]bool, len(members))
+	for _, m := range members {
+		current[m] = true
+	}
+
+	for m := range hc.fails {
+		if !current[m] {
+			delete(hc.fails, m)
+		}
+	}
+	for m := range hc.successes {
+		if !current[m] {
+			delete(hc.successes, m)
+		}
+	}
+}
+
+func (hc *healthChecker) onFailure(m *member, err error) {
+	hc.successes[m] = 0
+	hc.fails[m]++
+	if hc.fails[m] >= hc.conf.FailThreshold && !hc.cluster.isEjected(m) {
+		hc.cluster.eject(m, err)
+	}
+}
+
+func (hc *healthChecker) onSuccess(m *member) {
+	hc.fails[m] = 0
+	if !hc.cluster.isEjected(m) {
+		return
+	}
+
+	hc.successes[m]++
+	if hc.successes[m] >= hc.conf.RecoverThreshold {
+		if hc.cluster.rejoin(m, hc.conf.FlushOnRejoin) {
+			hc.successes[m] = 0
+		}
+	}
+}
+
+func (cc *cacheCluster) allMembers() []*member {
+	cc.lock.RLock()
+	defer cc.lock.RUnlock()
+
+	return append([]*member(nil), cc.members...)
+}
+
+// eject removes the node from the ring, the last node on the ring is never ejected,
+// because all the keys would be not found without any nodes.
+func (cc *cacheCluster) eject(m *member, err error) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	// the member might be removed, or replaced by a new one with the same name, since the check began
+	if m.ejected || !cc.isMember(m) {
+		return
+	}
+
+	var alive int
+	for _, v := range cc.members {
+		if !v.ejected {
+			alive++
+		}
+	}
+	if alive <= 1 {
+		cc.log().Error("unhealthy cache node is the last one on the ring, not ejected",
+			logx.Node(m.node.String()), logx.Err(err))
+		return
+	}
+
+	// the ejected node is down, so no transition to read it as the previous owner
+	cc.skipInTransition(m)
+	cc.dispatcher.Remove(m.node)
+	m.ejected = true
+	cc.stat.IncrementEjections()
+	cc.log().Warn("ejected unhealthy cache node from the ring", logx.Node(m.node.String()), logx.Err(err))
+}
+
+// isMember checks if m is still in the cluster by pointer, must be called with cc.lock held.
+func (cc *cacheCluster) isMember(m *member) bool {
+	for _, v := range cc.members {
+		if v == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cc *cacheCluster) isEjected(m *member) bool {
+	cc.lock.RLock()
+	defer cc.lock.RUnlock()
+
+	return m.ejected
+}
+
+// rejoin adds the node back to the ring with its original weight, returns false if failed to flush it.
+func (cc *cacheCluster) rejoin(m *member, flush bool) bool {
+	if flush {
+		// don't flush the removed member, its client might be released
+		cc.lock.RLock()
+		removed := !cc.isMember(m)
+		cc.lock.RUnlock()
+		if removed {
+			return true
+		}
+
+		if err := m.node.rds.FlushDB(context.Background()); err != nil {
+			cc.log().Error("failed to flush recovered cache node", logx.Node(m.node.String()), logx.Err(err))
+			return false
+		}
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	if !m.ejected || !cc.isMember(m) {
+		return true
+	}
+
+	cc.beginTransition()
+	cc.dispatcher.AddWithWeight(m.node, m.weight)
+	m.ejected = false
+	cc.stat.IncrementRejoins()
+	cc.log().Info("added recovered cache node back to the ring", logx.Node(m.node.String()))
+
+	return true
+}
+
+func (cc *cacheCluster) log() logx.Logger {
+	return logx.OrGlobal(cc.logger)
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"redis-cache/singleflight"
+)
+
+var (
+	errTestPing     = errors.New("ping failed")
+	errTestNotFound = errors.New("not found")
+)
+
+func TestEjectRemovedMember(t *testing.T) {
+	cc := newTestCluster([]string{"a:6379", "b:6379", "c:6379"})
+	// the member is taken by the health checker before it's removed and added back
+	old := cc.findMember("b:6379")
+	if err := cc.RemoveNode("b:6379"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.AddNode(NodeConf{Host: "b:6379", Type: NodeType, Weight: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	cc.eject(old, errTestPing)
+	if m := cc.findMember("b:6379"); m == nil || m.ejected {
+		t.Fatal("expected the added member not ejected")
+	}
+	if nodes := ringNodes(cc); len(nodes) != 3 || !nodes["b:6379"] {
+		t.Errorf("expected the added node on the ring, got %v", nodes)
+	}
+}
+
+func TestRejoinRemovedMember(t *testing.T) {
+	cc := newTestCluster([]string{"a:6379", "b:6379", "c:6379"})
+	old := cc.findMember("c:6379")
+	cc.eject(old, errTestPing)
+	if err := cc.RemoveNode("c:6379"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flush := range []bool{false, true} {
+		if !cc.rejoin(old, flush) {
+			t.Fatal("expected the removed member skipped")
+		}
+		if nodes := ringNodes(cc); len(nodes) != 2 || nodes["c:6379"] {
+			t.Errorf("expected the removed node not on the ring, got %v", nodes)
+		}
+	}
+}
+
+func newTestCluster(hosts []string, opts ...Option) *cacheCluster {
+	var c ClusterConf
+	for _, host := range hosts {
+		c = append(c, NodeConf{Host: host, Type: NodeType, Weight: 100})
+	}
+
+	return NewCache(c, singleflight.NewSharedCalls(), &CacheStat{name: "test"}, errTestNotFound,
+		opts...).(*cacheCluster)
+}
+
+// ringNodes returns the names of the nodes on the ring.
+func ringNodes(cc *cacheCluster) map[string]bool {
+	nodes := make(map[string]bool)
+	all, _ := cc.dispatcher.GetN("key", len(cc.members)+1)
+	for _, node := range all {
+		nodes[node.(cacheNode).String()] = true
+	}
+
+	return nodes
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// operation names passed to interceptors
+const (
+	OpDelCache           = "DelCache"
+	OpGetCache           = "GetCache"
+	OpSetCache           = "SetCache"
+	OpSetCacheWithExpire = "SetCacheWithExpire"
+	OpTake               = "Take"
+	OpTakeWithExpire     = "TakeWithExpire"
+)
+
+// ErrInvocationKey is returned if an interceptor leaves a single key operation without exactly one key.
+var ErrInvocationKey = errors.New("single key cache operation requires exactly one key")
+
+type (
+	// Invocation describes a cache operation passing through the interceptors,
+	// interceptors can rewrite Keys, Value and Expire before calling the next one.
+	Invocation struct {
+		Op     string
+		Keys   []string
+		Value  interface{}
+		Expire time.Duration
+	}
+
+	// Invoker invokes the rest of the chain, ends up with the underlying cache.
+	Invoker func(inv *Invocation) error
+
+	// Interceptor intercepts a cache operation, it calls next to continue the chain,
+	// or returns without calling next to short-circuit the operation.
+	Interceptor func(inv *Invocation, next Invoker) error
+
+	interceptedCache struct {
+		cache        Cache
+		interceptors []Interceptor
+	}
+)
+
+// Intercept wraps c with the interceptors, the first interceptor is the outermost one.
+func Intercept(c Cache, interceptors ...Interceptor) Cache {
+	if len(interceptors) == 0 {
+		return c
+	}
+
+	return interceptedCache{
+		cache:        c,
+		interceptors: interceptors,
+	}
+}
+
+func (ic interceptedCache) DelCache(keys ...string) error {
+	return ic.invoke(&Invocation{
+		Op:   OpDelCache,
+		Keys: keys,
+	}, func(inv *Invocation) error {
+		return ic.cache.DelCache(inv.Keys...)
+	})
+}
+
+func (ic interceptedCache) GetCache(key string, v interface{}) error {
+	return ic.invoke(&Invocation{
+		Op:    OpGetCache,
+		Keys:  []string{key},
+		Value: v,
+	}, func(inv *Invocation) error {
+		if len(inv.Keys) != 1 {
+			return ErrInvocationKey
+		}
+
+		return ic.cache.GetCache(inv.Keys[0], inv.Value)
+	})
+}
+
+func (ic interceptedCache) SetCache(key string, v interface{}) error {
+	return ic.invoke(&Invocation{
+		Op:    OpSetCache,
+		Keys:  []string{key},
+		Value: v,
+	}, func(inv *Invocation) error {
+		if len(inv.Keys) != 1 {
+			return ErrInvocationKey
+		}
+
+		return ic.cache.SetCache(inv.Keys[0], inv.Value)
+	})
+}
+
+func (ic interceptedCache) SetCacheWithExpire(key string, v interface{}, expire time.Duration) error {
+	return ic.invoke(&Invocation{
+		Op:     OpSetCacheWithExpire,
+		Keys:   []string{key},
+		Value:  v,
+		Expire: expire,
+	}, func(inv *Invocation) error {
+		if len(inv.Keys) != 1 {
+			return ErrInvocationKey
+		}
+
+		return ic.cache.SetCacheWithExpire(inv.Keys[0], inv.Value, inv.Expire)
+	})
+}
+
+func (ic interceptedCache) Take(v interface{}, key string, query func(v interface{}) error) error {
+	return ic.invoke(&Invocation{
+		Op:    OpTake,
+		Keys:  []string{key},
+		Value: v,
+	}, func(inv *Invocation) error {
+		if len(inv.Keys) != 1 {
+			return ErrInvocationKey
+		}
+
+		return ic.cache.Take(inv.Value, inv.Keys[0], query)
+	})
+}
+
+func (ic interceptedCache) TakeWithExpire(v interface{}, key string,
+	query func(v interface{}, expire time.Duration) error) error {
+	return ic.invoke(&Invocation{
+		Op:    OpTakeWithExpire,
+		Keys:  []string{key},
+		Value: v,
+	}, func(inv *Invocation) error {
+		if len(inv.Keys) != 1 {
+			return ErrInvocationKey
+		}
+
+		return ic.cache.TakeWithExpire(inv.Value, inv.Keys[0], query)
+	})
+}
+
+func (ic interceptedCache) invoke(inv *Invocation, invoker Invoker) error {
+	next := invoker
+	for i := len(ic.interceptors) - 1; i >= 0; i-- {
+		interceptor, n := ic.interceptors[i], next
+		next = func(inv *Invocation) error {
+			return interceptor(inv, n)
+		}
+	}
+
+	return next(inv)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeCache struct {
+	keys []string
+}
+
+func (c *fakeCache) DelCache(keys ...string) error {
+	c.keys = append(c.keys, keys...)
+	return nil
+}
+
+func (c *fakeCache) GetCache(key string, _ interface{}) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *fakeCache) SetCache(key string, _ interface{}) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *fakeCache) SetCacheWithExpire(key string, _ interface{}, _ time.Duration) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *fakeCache) Take(_ interface{}, key string, _ func(v interface{}) error) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *fakeCache) TakeWithExpire(_ interface{}, key string, _ func(v interface{}, expire time.Duration) error) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func TestInterceptRewritesKeys(t *testing.T) {
+	fc := new(fakeCache)
+	c := Intercept(fc, func(inv *Invocation, next Invoker) error {
+		for i, key := range inv.Keys {
+			inv.Keys[i] = "prefix:" + key
+		}
+		return next(inv)
+	})
+
+	if err := c.SetCache("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DelCache("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"prefix:a", "prefix:b", "prefix:c"}
+	if len(fc.keys) != len(expect) {
+		t.Fatalf("expected keys %v, got %v", expect, fc.keys)
+	}
+	for i := range expect {
+		if fc.keys[i] != expect[i] {
+			t.Fatalf("expected keys %v, got %v", expect, fc.keys)
+		}
+	}
+}
+
+func TestInterceptBadKeys(t *testing.T) {
+	for _, keys := range [][]string{nil, {}, {"a", "b"}} {
+		fc := new(fakeCache)
+		c := Intercept(fc, func(inv *Invocation, next Invoker) error {
+			inv.Keys = keys
+			return next(inv)
+		})
+
+		var v int
+		for op, fn := range map[string]func() error{
+			OpGetCache: func() error {
+				return c.GetCache("key", &v)
+			},
+			OpSetCache: func() error {
+				return c.SetCache("key", v)
+			},
+			OpSetCacheWithExpire: func() error {
+				return c.SetCacheWithExpire("key", v, time.Second)
+			},
+			OpTake: func() error {
+				return c.Take(&v, "key", func(v interface{}) error {
+					return nil
+				})
+			},
+			OpTakeWithExpire: func() error {
+				return c.TakeWithExpire(&v, "key", func(v interface{}, expire time.Duration) error {
+					return nil
+				})
+			},
+		} {
+			if err := fn(); err != ErrInvocationKey {
+				t.Errorf("%s with keys %v: expected ErrInvocationKey, got %v", op, keys, err)
+			}
+		}
+		if len(fc.keys) != 0 {
+			t.Errorf("expected the cache not called, got %v", fc.keys)
+		}
+	}
+}
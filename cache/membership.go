@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"redis-cache/logx"
+	"time"
+)
+
+// the connections of the removed nodes are closed after the in-flight requests are done
+const removedNodeCloseDelay = 5 * time.Second
+
+var (
+	ErrNodeExists   = errors.New("cache node already exists")
+	ErrNodeNotFound = errors.New("cache node not found")
+	ErrLastNode     = errors.New("can't remove the last cache node")
+)
+
+type interceptedCluster struct {
+	Cache
+	cluster *cacheCluster
+}
+
+func (ic interceptedCluster) AddNode(c NodeConf) error {
+	return ic.cluster.AddNode(c)
+}
+
+func (ic interceptedCluster) RemoveNode(host string) error {
+	return ic.cluster.RemoveNode(host)
+}
+
+func (ic interceptedCluster) SetWeight(host string, weight int) error {
+	return ic.cluster.SetWeight(host, weight)
+}
+
+func (ic interceptedCluster) Close() error {
+	return ic.cluster.Close()
+}
+
+func (cc *cacheCluster) AddNode(c NodeConf) error {
+	if c.Weight <= 0 {
+		return fmt.Errorf("bad weight %d of cache node %q", c.Weight, c.String())
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	if cc.findMember(c.String()) != nil {
+		return ErrNodeExists
+	}
+
+	cc.beginTransition()
+	cn := cc.newNode(c)
+	cc.members = append(cc.members, &member{
+		node:   cn,
+		weight: c.Weight,
+	})
+	cc.dispatcher.AddWithWeight(cn, c.Weight)
+	cc.log().Info("added cache node", logx.Node(cn.String()), logx.Any("weight", c.Weight))
+
+	return nil
+}
+
+func (cc *cacheCluster) RemoveNode(host string) error {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	m := cc.findMember(host)
+	if m == nil {
+		return ErrNodeNotFound
+	}
+	if len(cc.members) == 1 {
+		return ErrLastNode
+	}
+
+	cc.beginTransition()
+	cc.dispatcher.Remove(m.node)
+	members := cc.members[:0]
+	for _, v := range cc.members {
+		if v != m {
+			members = append(members, v)
+		}
+	}
+	cc.members = members
+
+	// the ejected nodes are not on the ring, make sure the ring isn't left empty
+	if !cc.hasAliveMember() {
+		alive := cc.members[0]
+		alive.ejected = false
+		cc.dispatcher.AddWithWeight(alive.node, alive.weight)
+	}
+
+	// the removed node is still read during the transition window, release it afterwards,
+	// the connections are closed only if not held by the others, like the same node added back
+	closeDelay := removedNodeCloseDelay
+	if cc.transitionWindow > closeDelay {
+		closeDelay = cc.transitionWindow
+	}
+	cc.log().Info("removed cache node", logx.Node(m.node.String()))
+	time.AfterFunc(closeDelay, func() {
+		// the window might be extended by the later changes, don't read the released node anymore
+		cc.lock.Lock()
+		cc.skipInTransition(m)
+		cc.lock.Unlock()
+
+		if err := m.node.rds.release(); err != nil {
+			cc.log().Error("failed to close removed cache node", logx.Node(m.node.String()), logx.Err(err))
+		}
+	})
+
+	return nil
+}
+
+func (cc *cacheCluster) SetWeight(host string, weight int) error {
+	if weight <= 0 {
+		return fmt.Errorf("bad weight %d of cache node %q", weight, host)
+	}
+
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	m := cc.findMember(host)
+	if m == nil {
+		return ErrNodeNotFound
+	}
+
+	m.weight = weight
+	// the ejected nodes are added back with the new weight once recovered
+	if !m.ejected {
+		cc.beginTransition()
+		cc.dispatcher.AddWithWeight(m.node, weight)
+	}
+	cc.log().Info("changed weight of cache node", logx.Node(host), logx.Any("weight", weight))
+
+	return nil
+}
+
+func (cc *cacheCluster) findMember(name string) *member {
+	for _, m := range cc.members {
+		if m.node.String() == name {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (cc *cacheCluster) hasAliveMember() bool {
+	for _, m := range cc.members {
+		if !m.ejected {
+			return true
+		}
+	}
+
+	return false
+}
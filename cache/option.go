@@ -1,6 +1,10 @@
 package cache
 
-import "time"
+import (
+	"time"
+
+	"redis-cache/logx"
+)
 
 const (
 	defaultExpiry         = time.Hour * 24 * 7
@@ -9,8 +13,20 @@ const (
 
 type (
 	Options struct {
-		Expiry         time.Duration
-		NotFoundExpiry time.Duration
+		Expiry            time.Duration
+		NotFoundExpiry    time.Duration
+		Logger            logx.Logger
+		Interceptors      []Interceptor
+		CorruptionHandler CorruptionHandler
+		QuarantineExpiry  time.Duration
+		FallbackPolicy    FallbackPolicy
+		Degrader          *Degrader
+		HealthCheck       *HealthCheckConf
+		TransitionWindow  time.Duration
+		Replicas          int
+		Balancer          string
+		LoadBound         float64
+		KeyExtractor      KeyExtractor
 	}
 
 	Option func(o *Options)
@@ -21,7 +37,7 @@ func newOptions(opts ...Option) Options {
 	for _, opt := range opts {
 		opt(&o)
 	}
- 
+
 	if o.Expiry <= 0 {
 		o.Expiry = defaultExpiry
 	}
@@ -43,3 +59,109 @@ func WithNotFoundExpiry(expiry time.Duration) Option {
 		o.NotFoundExpiry = expiry
 	}
 }
+
+// WithLogger sets the logger of the cache, the global logger is used if not set.
+func WithLogger(logger logx.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithInterceptors appends interceptors to wrap the cache with, in the given order.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *Options) {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+	}
+}
+
+// WithCorruptionHandler sets the handler to be called on the cached values that can't be unmarshaled.
+func WithCorruptionHandler(handler CorruptionHandler) Option {
+	return func(o *Options) {
+		o.CorruptionHandler = handler
+	}
+}
+
+// WithQuarantine copies the corrupt values to the quarantine keys with the given expiry before deleting them.
+func WithQuarantine(expiry time.Duration) Option {
+	return func(o *Options) {
+		o.QuarantineExpiry = expiry
+	}
+}
+
+// WithFallbackPolicy sets the policy to decide whether Take queries the loader directly,
+// if the cache fails with the given error.
+func WithFallbackPolicy(policy FallbackPolicy) Option {
+	return func(o *Options) {
+		o.FallbackPolicy = policy
+	}
+}
+
+// WithDegrader enables the degraded mode, Take queries the loader through d when the cache fails.
+// If a FallbackPolicy is set, it decides which errors to degrade on, otherwise all the cache errors do.
+func WithDegrader(d *Degrader) Option {
+	return func(o *Options) {
+		o.Degrader = d
+	}
+}
+
+// WithHealthCheck enables the health checker of the cluster, which ejects the unhealthy nodes
+// from the ring, and adds them back once recovered.
+func WithHealthCheck(c HealthCheckConf) Option {
+	return func(o *Options) {
+		o.HealthCheck = &c
+	}
+}
+
+// WithTransition keeps the previous ring for the window after the membership of the cluster changes,
+// the reads missed on the new owners are retried on the previous owners and copied forward,
+// and the deletes go to both owners.
+func WithTransition(window time.Duration) Option {
+	return func(o *Options) {
+		o.TransitionWindow = window
+	}
+}
+
+// WithReplication keeps the values on n nodes walking clockwise on the ring, the writes and deletes
+// go to all the replicas, and the reads fall back to the next replica if the previous one fails.
+func WithReplication(n int) Option {
+	return func(o *Options) {
+		o.Replicas = n
+	}
+}
+
+// WithBalancer sets the algorithm to dispatch the keys to the nodes, like hash.JumpBalancer,
+// hash.RendezvousBalancer or hash.MaglevBalancer, the consistent hash ring is used by default.
+// The jump balancer only keeps the keys in place when the nodes are added or removed at the end,
+// removing a node in the middle by RemoveNode moves the keys of all the nodes after it,
+// so it's meant for the clusters that only scale out, and it can't be used with WithHealthCheck.
+// The maglev balancer keeps a lookup table of 65537 slots (256KB) for each ring, including the
+// previous ring kept by WithTransition, the size is fixed because resizing it would move most
+// of the keys, and it's large enough to keep the shares even for hundreds of nodes.
+func WithBalancer(name string) Option {
+	return func(o *Options) {
+		o.Balancer = name
+	}
+}
+
+// WithBoundedLoad enables the consistent hashing with bounded loads, the keys are dispatched to the next
+// nodes on the ring if their owners have more than (1+epsilon) times their share of the in-flight requests.
+// It's only supported by the ring balancer, and only the primaries are bounded with WithReplication.
+// Because the keys might be cached on any node, the deletes go to all the nodes, and SetCache
+// writes the current owners and deletes the key from the other nodes, so the stale copies
+// are not read back once the loads change.
+// It can't be used with WithKeyExtractor, because the keys with the same tag might be dispatched to
+// different nodes under loads, which breaks the co-location.
+func WithBoundedLoad(epsilon float64) Option {
+	return func(o *Options) {
+		o.LoadBound = epsilon
+	}
+}
+
+// WithKeyExtractor dispatches the keys to the nodes by the parts extracted by fn,
+// like WithKeyExtractor(HashTag) to co-locate the keys with the same {tag} on one node.
+// It can't be used with WithBoundedLoad.
+func WithKeyExtractor(fn KeyExtractor) Option {
+	return func(o *Options) {
+		o.KeyExtractor = fn
+	}
+}
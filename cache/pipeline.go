@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+type (
+	// Pipeliner queues the commands to be sent in one round trip.
+	Pipeliner = rdb.Pipeliner
+	// Cmder is a queued command, its result is available after the pipeline is executed.
+	Cmder = rdb.Cmder
+)
+
+// Pipeline queues the commands in fn, and sends them in one round trip, the results are
+// in the returned commands in order. The returned error is the first failed command's,
+// the missing keys don't count as failures, check the commands for rdb.Nil instead.
+// For the cluster type, the commands are grouped by the nodes that own the keys.
+func (r *Redis) Pipeline(ctx context.Context, fn func(p Pipeliner) error) (cmds []Cmder, err error) {
+	err = r.do(func(conn RedisNode) error {
+		cmds, err = conn.Pipelined(ctx, fn)
+		return firstError(cmds, err)
+	})
+
+	return
+}
+
+// TxPipeline is like Pipeline, but wraps the commands in MULTI/EXEC to run them atomically.
+// For the cluster type, the keys must be in the same slot.
+func (r *Redis) TxPipeline(ctx context.Context, fn func(p Pipeliner) error) (cmds []Cmder, err error) {
+	err = r.do(func(conn RedisNode) error {
+		cmds, err = conn.TxPipelined(ctx, fn)
+		return firstError(cmds, err)
+	})
+
+	return
+}
+
+// firstError returns the first error of the commands other than rdb.Nil,
+// err is returned if it's not from the commands, like the error returned by fn.
+func firstError(cmds []Cmder, err error) error {
+	if err == nil {
+		return nil
+	}
+	if len(cmds) == 0 {
+		return err
+	}
+
+	for _, cmd := range cmds {
+		if e := cmd.Err(); e != nil && e != rdb.Nil {
+			return e
+		}
+	}
+
+	return nil
+}
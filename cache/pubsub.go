@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrSubscribeNotSupported is returned if the redis client doesn't support subscribing.
+	ErrSubscribeNotSupported = errors.New("subscribe is not supported by the redis client")
+
+	subscriptionManager = NewResourceManager()
+	subscriptionId      uint64
+)
+
+type (
+	// Message is a message received from a subscribed channel.
+	Message = rdb.Message
+
+	// Subscription receives the messages of the subscribed channels, it reconnects automatically
+	// on connection errors, the messages published while reconnecting are lost.
+	Subscription struct {
+		key    string
+		pubsub *rdb.PubSub
+		ch     <-chan *Message
+	}
+
+	subscriber interface {
+		Subscribe(ctx context.Context, channels ...string) *rdb.PubSub
+	}
+)
+
+// Publish publishes the message to the channel, returns the number of the receivers.
+func (r *Redis) Publish(ctx context.Context, channel, message string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.Publish(ctx, channel, message).Result()
+		return err
+	})
+
+	return
+}
+
+// Subscribe subscribes the channels, and waits for the subscription to be confirmed.
+// The subscription is managed by the resource manager until it's closed.
+func (r *Redis) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	var pubsub *rdb.PubSub
+	err := r.do(func(conn RedisNode) error {
+		sub, ok := conn.(subscriber)
+		if !ok {
+			return ErrSubscribeNotSupported
+		}
+
+		pubsub = sub.Subscribe(ctx, channels...)
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s#%d", r.resourceKey(), atomic.AddUint64(&subscriptionId, 1))
+	if _, err = subscriptionManager.GetResource(key, func() (io.Closer, error) {
+		return pubsub, nil
+	}); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	return &Subscription{
+		key:    key,
+		pubsub: pubsub,
+		ch:     pubsub.Channel(),
+	}, nil
+}
+
+// Channel returns the channel of the messages, which is closed after the subscription is closed.
+func (s *Subscription) Channel() <-chan *Message {
+	return s.ch
+}
+
+// Close unsubscribes the channels and releases the connection.
+func (s *Subscription) Close() error {
+	return subscriptionManager.Remove(s.key)
+}
@@ -5,14 +5,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"redis-cache/breaker"
+	"redis-cache/hash"
+	"redis-cache/logx"
+
 	rdb "github.com/go-redis/redis/v8"
 )
 
 const (
 	ClusterType     = "cluster"
 	NodeType        = "node"
+	SentinelType    = "sentinel"
 	Nil             = rdb.Nil
 	defaultDatabase = 0
 
@@ -23,23 +31,46 @@ const (
 )
 
 var (
-	ErrNilNode     = errors.New("nil redis node")
-	clusterManager = NewResourceManager()
-	clientManager  = NewResourceManager()
+	ErrNilNode      = errors.New("nil redis node")
+	ErrNoMasterName = errors.New("master name is required for sentinel redis")
+	ErrClusterDB    = errors.New("redis cluster only supports db 0")
+	clusterManager  = NewResourceManager()
+	clientManager   = NewResourceManager()
+	sentinelManager = NewResourceManager()
+
+	// ErrReplicaReadsDB is returned for the sentinel redis with ReplicaReads on a db other than 0,
+	// because the failover cluster client of go-redis doesn't select the db.
+	ErrReplicaReadsDB = errors.New("sentinel redis with replica reads only supports db 0")
 )
 
 type (
 	Redis struct {
-		Addr string
-		Type string
-		Pass string
+		Addr         string
+		Type         string
+		Pass         string
+		User         string
+		DB           int
+		Tls          TlsConf
+		PoolSize     int
+		MinIdleConns int
+		DialTimeout  time.Duration
+		ReadTimeout  time.Duration
+		WriteTimeout time.Duration
+		MaxRetries   int
+		MasterName   string
+		SentinelPass string
+		ReplicaReads bool
+		BreakerConf  breaker.Conf
+		brk          breaker.Breaker
+		brkOnce      sync.Once
 	}
 	RedisNode interface {
 		rdb.Cmdable
 	}
 )
 
-// NewRedis  the type is node or cluster
+// NewRedis  the type is node, cluster or sentinel, the sentinel type requires MasterName to be set,
+// and redisAddr to be the comma separated sentinel addresses.
 func NewRedis(redisAddr, redisType string, redisPass ...string) *Redis {
 	var pass string
 	for _, v := range redisPass {
@@ -50,28 +81,31 @@ func NewRedis(redisAddr, redisType string, redisPass ...string) *Redis {
 		Addr: redisAddr,
 		Type: redisType,
 		Pass: pass,
+		DB:   defaultDatabase,
 	}
 }
 
 func getRedis(r *Redis) (RedisNode, error) {
 	switch r.Type {
 	case ClusterType:
-		return getCluster(r.Addr, r.Pass)
+		return getCluster(r)
 	case NodeType:
-		return getClient(r.Addr, r.Pass)
+		return getClient(r)
+	case SentinelType:
+		return getSentinel(r)
 	default:
 		return nil, fmt.Errorf("redis type '%s' is not supported", r.Type)
 	}
 }
 
-func getCluster(server, pass string) (*rdb.ClusterClient, error) {
-	val, err := clusterManager.GetResource(server, func() (io.Closer, error) {
-		store := rdb.NewClusterClient(&rdb.ClusterOptions{
-			Addrs:    []string{server},
-			Password: pass,
-		})
+func getCluster(r *Redis) (*rdb.ClusterClient, error) {
+	val, err := clusterManager.GetResource(r.resourceKey(), func() (io.Closer, error) {
+		opt, err := r.clusterOptions()
+		if err != nil {
+			return nil, err
+		}
 
-		return store, nil
+		return rdb.NewClusterClient(opt), nil
 	})
 	if err != nil {
 		return nil, err
@@ -80,12 +114,25 @@ func getCluster(server, pass string) (*rdb.ClusterClient, error) {
 	return val.(*rdb.ClusterClient), nil
 }
 
-func getClient(server, pass string) (*rdb.Client, error) {
-	val, err := clientManager.GetResource(server, func() (io.Closer, error) {
+func getClient(r *Redis) (*rdb.Client, error) {
+	val, err := clientManager.GetResource(r.resourceKey(), func() (io.Closer, error) {
+		tlsConfig, err := r.Tls.TlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
 		store := rdb.NewClient(&rdb.Options{
-			Addr:     server,
-			Password: pass,
-			DB:       defaultDatabase,
+			Addr:         r.Addr,
+			Username:     r.User,
+			Password:     r.Pass,
+			DB:           r.DB,
+			MaxRetries:   r.MaxRetries,
+			DialTimeout:  r.DialTimeout,
+			ReadTimeout:  r.readTimeout(),
+			WriteTimeout: r.writeTimeout(),
+			PoolSize:     r.PoolSize,
+			MinIdleConns: r.MinIdleConns,
+			TLSConfig:    tlsConfig,
 		})
 
 		return store, nil
@@ -97,35 +144,295 @@ func getClient(server, pass string) (*rdb.Client, error) {
 	return val.(*rdb.Client), nil
 }
 
-func (r *Redis) Get(ctx context.Context, key string) (val string, err error) {
-	conn, err := getRedis(r)
-	if err != nil {
-		return
+// getSentinel returns a failover client of the master, and if ReplicaReads is set,
+// a failover cluster client that routes the read-only commands to the replicas as well.
+func getSentinel(r *Redis) (RedisNode, error) {
+	if len(r.MasterName) == 0 {
+		return nil, ErrNoMasterName
+	}
+	if r.ReplicaReads && r.DB != defaultDatabase {
+		return nil, ErrReplicaReadsDB
 	}
 
-	if val, err = conn.Get(ctx, key).Result(); err == rdb.Nil {
-		return val, nil
-	} else if err != nil {
-		return
+	val, err := sentinelManager.GetResource(r.resourceKey(), func() (io.Closer, error) {
+		tlsConfig, err := r.Tls.TlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		opt := &rdb.FailoverOptions{
+			MasterName:       r.MasterName,
+			SentinelAddrs:    splitAddrs(r.Addr),
+			SentinelPassword: r.SentinelPass,
+			RouteRandomly:    r.ReplicaReads,
+			Username:         r.User,
+			Password:         r.Pass,
+			DB:               r.DB,
+			MaxRetries:       r.MaxRetries,
+			DialTimeout:      r.DialTimeout,
+			ReadTimeout:      r.readTimeout(),
+			WriteTimeout:     r.writeTimeout(),
+			PoolSize:         r.PoolSize,
+			MinIdleConns:     r.MinIdleConns,
+			TLSConfig:        tlsConfig,
+		}
+		if r.ReplicaReads {
+			return rdb.NewFailoverClusterClient(opt), nil
+		}
+
+		return rdb.NewFailoverClient(opt), nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return val.(RedisNode), nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (val string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if val, err = conn.Get(ctx, key).Result(); err == rdb.Nil {
+			return nil
+		}
+
+		return err
+	})
+
 	return
 }
 
 func (r *Redis) Set(ctx context.Context, key, val string, expire time.Duration) error {
+	return r.do(func(conn RedisNode) error {
+		return conn.Set(ctx, key, val, expire).Err()
+	})
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	return r.do(func(conn RedisNode) error {
+		return conn.Del(ctx, keys...).Err()
+	})
+}
+
+// Ttl returns the remaining seconds of the key, -1 if the key has no expiry, -2 if the key doesn't exist.
+func (r *Redis) Ttl(ctx context.Context, key string) (val int, err error) {
+	err = r.do(func(conn RedisNode) error {
+		ttl, err := conn.TTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		if ttl < 0 {
+			val = int(ttl)
+		} else {
+			val = int(ttl / time.Second)
+		}
+		return nil
+	})
+
+	return
+}
+
+// Ping checks if the redis is reachable, it bypasses the breaker to reflect the actual health.
+func (r *Redis) Ping(ctx context.Context) error {
 	conn, err := getRedis(r)
 	if err != nil {
 		return err
 	}
 
-	return conn.Set(ctx, key, val, expire).Err()
+	return conn.Ping(ctx).Err()
 }
 
-func (r *Redis) Del(ctx context.Context, keys ...string) error {
-	conn, err := getRedis(r)
+// FlushDB deletes all the keys in the database, for the cluster type, in all the masters.
+func (r *Redis) FlushDB(ctx context.Context) error {
+	return r.do(func(conn RedisNode) error {
+		if cluster, ok := conn.(*rdb.ClusterClient); ok {
+			return cluster.ForEachMaster(ctx, func(ctx context.Context, client *rdb.Client) error {
+				return client.FlushDB(ctx).Err()
+			})
+		}
+
+		return conn.FlushDB(ctx).Err()
+	})
+}
+
+// AddBreakerListener adds a listener to be notified when the breaker of the redis changes its state.
+func (r *Redis) AddBreakerListener(listener breaker.StateListener) {
+	r.breaker().AddListener(listener)
+}
+
+// acquire holds a reference to the shared client of the redis, so that it's not closed
+// by the others sharing it, like the same node in another cache, or the node added back.
+func (r *Redis) acquire() {
+	if manager := r.manager(); manager != nil {
+		manager.Acquire(r.resourceKey())
+	}
+}
+
+// release releases the reference held by acquire, and closes the shared client if it's the last one,
+// the client is recreated if the redis is used again.
+func (r *Redis) release() error {
+	if manager := r.manager(); manager != nil {
+		return manager.Release(r.resourceKey())
+	}
+
+	return nil
+}
+
+func (r *Redis) manager() *ResourceManager {
+	switch r.Type {
+	case ClusterType:
+		return clusterManager
+	case NodeType:
+		return clientManager
+	case SentinelType:
+		return sentinelManager
+	default:
+		return nil
+	}
+}
+
+// clusterOptions returns the options of the cluster client, Addr is the comma separated seed addresses.
+func (r *Redis) clusterOptions() (*rdb.ClusterOptions, error) {
+	if r.DB != defaultDatabase {
+		return nil, ErrClusterDB
+	}
+
+	tlsConfig, err := r.Tls.TlsConfig()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return &rdb.ClusterOptions{
+		Addrs:        splitAddrs(r.Addr),
+		Username:     r.User,
+		Password:     r.Pass,
+		MaxRetries:   r.MaxRetries,
+		DialTimeout:  r.DialTimeout,
+		ReadTimeout:  r.readTimeout(),
+		WriteTimeout: r.writeTimeout(),
+		PoolSize:     r.PoolSize,
+		MinIdleConns: r.MinIdleConns,
+		TLSConfig:    tlsConfig,
+	}, nil
+}
+
+// String returns the identity of the redis on the ring, it's the address for the node type,
+// the normalized seed addresses for the cluster type, and the master name followed by
+// the normalized sentinel addresses for the sentinel type.
+func (r *Redis) String() string {
+	return nodeName(r.Type, r.Addr, r.MasterName)
+}
+
+// resourceKey identifies the shared client, the clients on the same address are shared only if they
+// have the same db, user and connection settings, the settings are hashed to keep the secrets out of the key.
+func (r *Redis) resourceKey() string {
+	name := r.String()
+	settings := r.settings()
+	if r.DB == defaultDatabase && len(r.User) == 0 && len(settings) == 0 {
+		return name
+	}
+
+	key := fmt.Sprintf("%s/%d/%s", name, r.DB, r.User)
+	if len(settings) > 0 {
+		key = fmt.Sprintf("%s#%x", key, hash.Hash([]byte(settings)))
+	}
+
+	return key
+}
+
+// settings returns the connection settings that the clients can't be shared across, empty if all default.
+func (r *Redis) settings() string {
+	var zero TlsConf
+	if len(r.Pass) == 0 && r.Tls == zero && r.PoolSize == 0 && r.MinIdleConns == 0 && r.DialTimeout == 0 &&
+		r.ReadTimeout == 0 && r.WriteTimeout == 0 && r.MaxRetries == 0 && len(r.SentinelPass) == 0 && !r.ReplicaReads {
+		return ""
+	}
+
+	return fmt.Sprintf("%s|%+v|%d|%d|%v|%v|%v|%d|%s|%t", r.Pass, r.Tls, r.PoolSize, r.MinIdleConns,
+		r.DialTimeout, r.ReadTimeout, r.WriteTimeout, r.MaxRetries, r.SentinelPass, r.ReplicaReads)
+}
+
+func (r *Redis) breaker() breaker.Breaker {
+	r.brkOnce.Do(func() {
+		if r.brk == nil {
+			r.brk = breaker.NewBreaker(r.String(), r.BreakerConf)
+			r.brk.AddListener(func(name string, from, to breaker.State) {
+				logx.GetLogger().Warn("redis breaker state changed", logx.Node(name),
+					logx.Any("from", from), logx.Any("to", to))
+			})
+		}
+	})
+
+	return r.brk
+}
+
+// do runs fn on the connection through the breaker, the breaker fails fast with
+// breaker.ErrServiceUnavailable if the redis keeps failing.
+func (r *Redis) do(fn func(conn RedisNode) error) error {
+	return r.breaker().DoWithAcceptable(func() error {
+		conn, err := getRedis(r)
+		if err != nil {
+			return err
+		}
+
+		return fn(conn)
+	}, acceptable)
+}
+
+func (r *Redis) readTimeout() time.Duration {
+	if r.ReadTimeout > 0 {
+		return r.ReadTimeout
+	}
+
+	return readWriteTimeout
+}
+
+func (r *Redis) writeTimeout() time.Duration {
+	if r.WriteTimeout > 0 {
+		return r.WriteTimeout
+	}
+
+	return readWriteTimeout
+}
+
+func acceptable(err error) bool {
+	return err == nil || err == rdb.Nil || err == context.Canceled
+}
+
+func nodeName(redisType, addr, masterName string) string {
+	switch {
+	case redisType == ClusterType:
+		return normalizeAddrs(addr)
+	case redisType == SentinelType && len(masterName) > 0:
+		return masterName + "@" + normalizeAddrs(addr)
+	default:
+		return addr
+	}
+}
+
+// normalizeAddrs sorts and dedupes the comma separated addresses.
+func normalizeAddrs(addrs string) string {
+	vals := splitAddrs(addrs)
+	sort.Strings(vals)
+
+	var n int
+	for i, addr := range vals {
+		if i == 0 || addr != vals[n-1] {
+			vals[n] = addr
+			n++
+		}
+	}
+
+	return strings.Join(vals[:n], ",")
+}
+
+func splitAddrs(addrs string) []string {
+	var vals []string
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			vals = append(vals, addr)
+		}
 	}
 
-	return conn.Del(ctx, keys...).Err()
+	return vals
 }
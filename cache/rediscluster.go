@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"redis-cache/logx"
+	"strings"
+	"sync"
+	"time"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+const clusterProbeTimeout = 2 * time.Second
+
+// mergeClusters merges the cluster type nodes that point into the same redis cluster into one node,
+// the seeds are merged, and the weights are summed up. The redis cluster shards the keys itself,
+// sharding them again on the ring splits one cluster into several nodes for nothing.
+// Two nodes are in the same redis cluster if they share any seed address. It only depends on the config,
+// so that all the processes with the same config build the same ring.
+func mergeClusters(c ClusterConf) ClusterConf {
+	return mergeClustersBy(c, func(node NodeConf) []string {
+		return splitAddrs(node.Host)
+	})
+}
+
+// ProbeClusters merges the cluster type nodes that are in the same redis cluster by the members
+// reported by CLUSTER NODES, besides the shared seeds, like several nodes with one seed each.
+// It blocks on probing, and the result depends on which clusters are reachable, so the probed
+// config should be saved and shared by all the processes, rather than probed by each of them.
+func ProbeClusters(c ClusterConf) ClusterConf {
+	return mergeClustersBy(c, clusterMembers)
+}
+
+// mergeClustersBy merges the cluster type nodes that share any address returned by addrs.
+func mergeClustersBy(c ClusterConf, addrs func(node NodeConf) []string) ClusterConf {
+	var indexes []int
+	for i, node := range c {
+		if node.Type == ClusterType {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) < 2 {
+		return c
+	}
+
+	members := make([][]string, len(c))
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			members[i] = addrs(c[i])
+		}(i)
+	}
+	wg.Wait()
+
+	parents := make([]int, len(c))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	owners := make(map[string]int)
+	for _, i := range indexes {
+		for _, addr := range members[i] {
+			if j, ok := owners[addr]; ok {
+				parents[find(i)] = find(j)
+			} else {
+				owners[addr] = i
+			}
+		}
+	}
+
+	merged := make(ClusterConf, 0, len(c))
+	groups := make(map[int]int)
+	for i, node := range c {
+		if node.Type != ClusterType {
+			merged = append(merged, node)
+			continue
+		}
+
+		root := find(i)
+		pos, ok := groups[root]
+		if !ok {
+			groups[root] = len(merged)
+			node.Host = normalizeAddrs(node.Host)
+			merged = append(merged, node)
+			continue
+		}
+
+		logx.GetLogger().Info("merged cache nodes in the same redis cluster",
+			logx.Node(merged[pos].Host), logx.Any("merged", node.Host))
+		merged[pos].Host = normalizeAddrs(merged[pos].Host + "," + node.Host)
+		merged[pos].Weight += node.Weight
+	}
+
+	return merged
+}
+
+// clusterMembers returns the seeds of the node, and the members of the redis cluster if reachable.
+func clusterMembers(node NodeConf) []string {
+	seeds := splitAddrs(node.Host)
+	opt, err := node.NewRedis().clusterOptions()
+	if err != nil {
+		logx.GetLogger().Warn("failed to probe redis cluster", logx.Node(node.Host), logx.Err(err))
+		return seeds
+	}
+
+	client := rdb.NewClusterClient(opt)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterProbeTimeout)
+	defer cancel()
+	nodes, err := client.ClusterNodes(ctx).Result()
+	if err != nil {
+		logx.GetLogger().Warn("failed to probe redis cluster", logx.Node(node.Host), logx.Err(err))
+		return seeds
+	}
+
+	return append(seeds, parseClusterNodes(nodes)...)
+}
+
+// parseClusterNodes parses the addresses in the reply of CLUSTER NODES, each line is like:
+// <id> <ip:port@cport[,hostname]> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot>...
+func parseClusterNodes(nodes string) []string {
+	var addrs []string
+	for _, line := range strings.Split(nodes, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr := fields[1]
+		if pos := strings.IndexAny(addr, "@,"); pos >= 0 {
+			addr = addr[:pos]
+		}
+		// the node without address is reported as :0
+		if len(addr) > 0 && addr[0] != ':' {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
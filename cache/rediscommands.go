@@ -0,0 +1,392 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+// Pair is a member of a sorted set with its score.
+type Pair struct {
+	Member string
+	Score  float64
+}
+
+// Expire sets the expiry of the key, returns false if the key doesn't exist.
+func (r *Redis) Expire(ctx context.Context, key string, expire time.Duration) (val bool, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.Expire(ctx, key, expire).Result()
+		return err
+	})
+
+	return
+}
+
+// HGet returns the value of the field in the hash, empty string if the field doesn't exist.
+func (r *Redis) HGet(ctx context.Context, key, field string) (val string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if val, err = conn.HGet(ctx, key, field).Result(); err == rdb.Nil {
+			return nil
+		}
+
+		return err
+	})
+
+	return
+}
+
+// HGetAll returns all the fields and values in the hash.
+func (r *Redis) HGetAll(ctx context.Context, key string) (val map[string]string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.HGetAll(ctx, key).Result()
+		return err
+	})
+
+	return
+}
+
+func (r *Redis) HSet(ctx context.Context, key, field, value string) error {
+	return r.do(func(conn RedisNode) error {
+		return conn.HSet(ctx, key, field, value).Err()
+	})
+}
+
+// HMSet sets the fields in the hash.
+func (r *Redis) HMSet(ctx context.Context, key string, fieldsAndValues map[string]string) error {
+	vals := make(map[string]interface{}, len(fieldsAndValues))
+	for field, value := range fieldsAndValues {
+		vals[field] = value
+	}
+
+	return r.do(func(conn RedisNode) error {
+		return conn.HMSet(ctx, key, vals).Err()
+	})
+}
+
+// HMGet returns the values of the fields in the hash, empty strings for the fields that don't exist.
+func (r *Redis) HMGet(ctx context.Context, key string, fields ...string) (val []string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		vals, err := conn.HMGet(ctx, key, fields...).Result()
+		if err != nil {
+			return err
+		}
+
+		val = toStrings(vals)
+		return nil
+	})
+
+	return
+}
+
+// HDel deletes the fields in the hash, returns the number of the fields deleted.
+func (r *Redis) HDel(ctx context.Context, key string, fields ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.HDel(ctx, key, fields...).Result()
+		return err
+	})
+
+	return
+}
+
+// HIncrBy increments the field in the hash, returns the value after the increment.
+func (r *Redis) HIncrBy(ctx context.Context, key, field string, increment int64) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.HIncrBy(ctx, key, field, increment).Result()
+		return err
+	})
+
+	return
+}
+
+// IncrBy increments the key, returns the value after the increment.
+func (r *Redis) IncrBy(ctx context.Context, key string, increment int64) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.IncrBy(ctx, key, increment).Result()
+		return err
+	})
+
+	return
+}
+
+// DecrBy decrements the key, returns the value after the decrement.
+func (r *Redis) DecrBy(ctx context.Context, key string, decrement int64) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.DecrBy(ctx, key, decrement).Result()
+		return err
+	})
+
+	return
+}
+
+// SAdd adds the members into the set, returns the number of the members added.
+func (r *Redis) SAdd(ctx context.Context, key string, members ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.SAdd(ctx, key, toInterfaces(members)...).Result()
+		return err
+	})
+
+	return
+}
+
+// SRem removes the members from the set, returns the number of the members removed.
+func (r *Redis) SRem(ctx context.Context, key string, members ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.SRem(ctx, key, toInterfaces(members)...).Result()
+		return err
+	})
+
+	return
+}
+
+func (r *Redis) SMembers(ctx context.Context, key string) (val []string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.SMembers(ctx, key).Result()
+		return err
+	})
+
+	return
+}
+
+func (r *Redis) SIsMember(ctx context.Context, key, member string) (val bool, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.SIsMember(ctx, key, member).Result()
+		return err
+	})
+
+	return
+}
+
+func (r *Redis) SCard(ctx context.Context, key string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.SCard(ctx, key).Result()
+		return err
+	})
+
+	return
+}
+
+// LPush pushes the values to the head of the list, returns the length of the list.
+func (r *Redis) LPush(ctx context.Context, key string, values ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.LPush(ctx, key, toInterfaces(values)...).Result()
+		return err
+	})
+
+	return
+}
+
+// RPush pushes the values to the tail of the list, returns the length of the list.
+func (r *Redis) RPush(ctx context.Context, key string, values ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.RPush(ctx, key, toInterfaces(values)...).Result()
+		return err
+	})
+
+	return
+}
+
+// LPop pops the head of the list, empty string if the list is empty.
+func (r *Redis) LPop(ctx context.Context, key string) (val string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if val, err = conn.LPop(ctx, key).Result(); err == rdb.Nil {
+			return nil
+		}
+
+		return err
+	})
+
+	return
+}
+
+// RPop pops the tail of the list, empty string if the list is empty.
+func (r *Redis) RPop(ctx context.Context, key string) (val string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if val, err = conn.RPop(ctx, key).Result(); err == rdb.Nil {
+			return nil
+		}
+
+		return err
+	})
+
+	return
+}
+
+// BLPop pops the head of the list, blocks at most blockingQueryTimeout if the list is empty,
+// returns empty string if timed out.
+func (r *Redis) BLPop(ctx context.Context, key string) (val string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		vals, err := conn.BLPop(ctx, blockingQueryTimeout, key).Result()
+		if err == rdb.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// the reply is the key and the value
+		if len(vals) == 2 {
+			val = vals[1]
+		}
+		return nil
+	})
+
+	return
+}
+
+func (r *Redis) LRange(ctx context.Context, key string, start, stop int64) (val []string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.LRange(ctx, key, start, stop).Result()
+		return err
+	})
+
+	return
+}
+
+func (r *Redis) LLen(ctx context.Context, key string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.LLen(ctx, key).Result()
+		return err
+	})
+
+	return
+}
+
+// ZAdd adds the member with the score into the sorted set, returns false if the member exists,
+// in which case the score is updated.
+func (r *Redis) ZAdd(ctx context.Context, key string, score float64, member string) (val bool, err error) {
+	err = r.do(func(conn RedisNode) error {
+		n, err := conn.ZAdd(ctx, key, &rdb.Z{
+			Score:  score,
+			Member: member,
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		val = n == 1
+		return nil
+	})
+
+	return
+}
+
+// ZAdds adds the pairs into the sorted set, returns the number of the new members.
+func (r *Redis) ZAdds(ctx context.Context, key string, pairs ...Pair) (val int64, err error) {
+	members := make([]*rdb.Z, 0, len(pairs))
+	for _, pair := range pairs {
+		members = append(members, &rdb.Z{
+			Score:  pair.Score,
+			Member: pair.Member,
+		})
+	}
+
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.ZAdd(ctx, key, members...).Result()
+		return err
+	})
+
+	return
+}
+
+// ZRangeByScore returns the members with the scores in [min, max], ordered by the scores.
+func (r *Redis) ZRangeByScore(ctx context.Context, key string, min, max float64) (val []string, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.ZRangeByScore(ctx, key, scoreRange(min, max)).Result()
+		return err
+	})
+
+	return
+}
+
+// ZRangeByScoreWithScores returns the pairs with the scores in [min, max], ordered by the scores.
+func (r *Redis) ZRangeByScoreWithScores(ctx context.Context, key string, min, max float64) (val []Pair, err error) {
+	err = r.do(func(conn RedisNode) error {
+		vals, err := conn.ZRangeByScoreWithScores(ctx, key, scoreRange(min, max)).Result()
+		if err != nil {
+			return err
+		}
+
+		val = make([]Pair, 0, len(vals))
+		for _, z := range vals {
+			member, _ := z.Member.(string)
+			val = append(val, Pair{
+				Member: member,
+				Score:  z.Score,
+			})
+		}
+		return nil
+	})
+
+	return
+}
+
+// ZRem removes the members from the sorted set, returns the number of the members removed.
+func (r *Redis) ZRem(ctx context.Context, key string, members ...string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.ZRem(ctx, key, toInterfaces(members)...).Result()
+		return err
+	})
+
+	return
+}
+
+// ZScore returns the score of the member, ok is false if the member doesn't exist.
+func (r *Redis) ZScore(ctx context.Context, key, member string) (val float64, ok bool, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if val, err = conn.ZScore(ctx, key, member).Result(); err == rdb.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		ok = true
+		return nil
+	})
+
+	return
+}
+
+func (r *Redis) ZCard(ctx context.Context, key string) (val int64, err error) {
+	err = r.do(func(conn RedisNode) error {
+		val, err = conn.ZCard(ctx, key).Result()
+		return err
+	})
+
+	return
+}
+
+func scoreRange(min, max float64) *rdb.ZRangeBy {
+	return &rdb.ZRangeBy{
+		Min: strconv.FormatFloat(min, 'f', -1, 64),
+		Max: strconv.FormatFloat(max, 'f', -1, 64),
+	}
+}
+
+func toInterfaces(vals []string) []interface{} {
+	ret := make([]interface{}, len(vals))
+	for i, val := range vals {
+		ret[i] = val
+	}
+
+	return ret
+}
+
+func toStrings(vals []interface{}) []string {
+	ret := make([]string, len(vals))
+	for i, val := range vals {
+		if val == nil {
+			continue
+		}
+
+		switch v := val.(type) {
+		case string:
+			ret[i] = v
+		default:
+			ret[i] = fmt.Sprint(v)
+		}
+	}
+
+	return ret
+}
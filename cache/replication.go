@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"redis-cache/logx"
+	"redis-cache/utils"
+)
+
+// owners returns the nodes that keep the key, the primary goes first, followed by the replicas.
+// In the bounded-load mode, the primary is the one bounded by the loads, and the replicas are
+// the other owners on the ring, because GetN doesn't consider the loads.
+func (cc *cacheCluster) owners(key string) ([]Cache, bool) {
+	v := cc.dispatchKey(key)
+	if cc.replicas <= 1 {
+		c, ok := cc.dispatcher.Get(v)
+		if !ok {
+			return nil, false
+		}
+
+		return []Cache{c.(Cache)}, true
+	}
+
+	nodes, ok := cc.dispatcher.GetN(v, cc.replicas)
+	if !ok || len(nodes) == 0 {
+		return nil, false
+	}
+
+	if cc.loadBalancer != nil {
+		primary, ok := cc.dispatcher.Get(v)
+		if !ok {
+			return nil, false
+		}
+
+		nodes = withPrimary(primary, nodes)
+	}
+
+	owners := make([]Cache, len(nodes))
+	for i, node := range nodes {
+		owners[i] = node.(Cache)
+	}
+
+	return owners, true
+}
+
+// tryOwners calls fn on the owners in order until one of them succeeds or misses,
+// falls back to the next replica only if the previous one fails to read the cache.
+// loaded reports if the loader ran in fn, if so, the error is from the loader, like the db,
+// and returned without falling back, otherwise one db error would turn into a db call per replica.
+func (cc *cacheCluster) tryOwners(owners []Cache, loaded func() bool, fn func(c Cache) error) error {
+	var err error
+	for _, c := range owners {
+		if err = fn(c); err == nil || err == cc.errNotFound {
+			return err
+		}
+		if loaded != nil && loaded() {
+			return err
+		}
+	}
+
+	return err
+}
+
+// withPrimary puts primary first in nodes, the last node is dropped to keep the number of the nodes
+// if primary is not one of them.
+func withPrimary(primary interface{}, nodes []interface{}) []interface{} {
+	primaryRepr := utils.Repr(primary)
+	ret := make([]interface{}, 0, len(nodes))
+	ret = append(ret, primary)
+	for _, node := range nodes {
+		if len(ret) < len(nodes) && utils.Repr(node) != primaryRepr {
+			ret = append(ret, node)
+		}
+	}
+
+	return ret
+}
+
+// replicate copies the value loaded by the given owner to the other replicas,
+// failures are only logged, because the value is already cached on one of the owners.
+func (cc *cacheCluster) replicate(owners []Cache, from Cache, key string, fn func(c Cache) error) {
+	fromRepr := utils.Repr(from)
+	for _, c := range owners {
+		if utils.Repr(c) == fromRepr {
+			continue
+		}
+
+		if err := fn(c); err != nil {
+			cc.log().Error("failed to replicate cache", logx.Node(utils.Repr(c)), logx.Key(key), logx.Err(err))
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 type ResourceManager struct {
 	resources   map[string]io.Closer
+	refs        map[string]int
 	sharedCalls singleflight.SharedCalls
 	lock        sync.RWMutex
 }
@@ -16,6 +17,7 @@ type ResourceManager struct {
 func NewResourceManager() *ResourceManager {
 	return &ResourceManager{
 		resources:   make(map[string]io.Closer),
+		refs:        make(map[string]int),
 		sharedCalls: singleflight.NewSharedCalls(),
 	}
 }
@@ -60,3 +62,52 @@ func (manager *ResourceManager) GetResource(key string, create func() (io.Closer
 
 	return val.(io.Closer), nil
 }
+
+// Resources returns a copy of the managed resources by key.
+func (manager *ResourceManager) Resources() map[string]io.Closer {
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+
+	resources := make(map[string]io.Closer, len(manager.resources))
+	for key, resource := range manager.resources {
+		resources[key] = resource
+	}
+
+	return resources
+}
+
+// Acquire holds a reference to the resource with the key, the resource is kept open until
+// all the references are released, no matter it's created yet or not.
+func (manager *ResourceManager) Acquire(key string) {
+	manager.lock.Lock()
+	manager.refs[key]++
+	manager.lock.Unlock()
+}
+
+// Release releases a reference acquired by Acquire, and closes the resource with the key
+// if it's the last reference.
+func (manager *ResourceManager) Release(key string) error {
+	manager.lock.Lock()
+	if manager.refs[key]--; manager.refs[key] > 0 {
+		manager.lock.Unlock()
+		return nil
+	}
+	delete(manager.refs, key)
+	manager.lock.Unlock()
+
+	return manager.Remove(key)
+}
+
+// Remove closes the resource with the key, and removes it from the manager.
+func (manager *ResourceManager) Remove(key string) error {
+	manager.lock.Lock()
+	resource, ok := manager.resources[key]
+	delete(manager.resources, key)
+	manager.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return resource.Close()
+}
@@ -0,0 +1,37 @@
+package cache
+
+import "redis-cache/hash"
+
+// NewRing returns the ring that NewCache dispatches keys with, the nodes on the ring are the NodeConfs.
+// It's used to locate keys outside of the cache, like the command line tools.
+func NewRing(c ClusterConf) *hash.ConsistentHash {
+	ring := hash.NewConsistentHash()
+	addNodes(ring, mergeClusters(c), func(node NodeConf) interface{} {
+		return node
+	})
+
+	return ring
+}
+
+// NewDispatcher returns the balancer that NewCache dispatches keys with if created by WithBalancer(balancer),
+// the nodes in the balancer are the NodeConfs.
+func NewDispatcher(c ClusterConf, balancer string) (hash.Balancer, error) {
+	dispatcher, err := hash.NewBalancer(balancer)
+	if err != nil {
+		return nil, err
+	}
+
+	addNodes(dispatcher, mergeClusters(c), func(node NodeConf) interface{} {
+		return node
+	})
+
+	return dispatcher, nil
+}
+
+// addNodes adds the nodes created by fn into the dispatcher, the node must be represented by NodeConf.String(),
+// so that NewRing and NewCache locate the keys to the same nodes.
+func addNodes(dispatcher hash.Balancer, c ClusterConf, fn func(node NodeConf) interface{}) {
+	for _, node := range c {
+		dispatcher.AddWithWeight(fn(node), node.Weight)
+	}
+}
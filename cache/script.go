@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	rdb "github.com/go-redis/redis/v8"
+)
+
+var (
+	// deletes the key only if its value equals ARGV[1], returns the number of the keys deleted
+	compareAndDeleteScript = NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end`)
+	// returns the value of the key if exists, otherwise sets it to ARGV[1] with ARGV[2] milliseconds expiry,
+	// or without expiry if ARGV[2] is 0
+	getOrSetScript = NewScript(`local val = redis.call("GET", KEYS[1])
+if val then
+	return val
+end
+local expire = tonumber(ARGV[2])
+if expire > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", expire)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return false`)
+)
+
+// Script is a lua script, which is loaded once per redis node and run with EVALSHA,
+// and falls back to EVAL if the node doesn't have the script, like after a restart.
+type Script struct {
+	src    string
+	hash   string
+	loaded sync.Map
+}
+
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(sum[:]),
+	}
+}
+
+// Hash returns the SHA1 digest of the script, which is used by EVALSHA.
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// load loads the script into the redis node, for the cluster type, into all the masters,
+// because the keys of the script decide which master to run it.
+func (s *Script) load(ctx context.Context, node string, conn RedisNode) error {
+	if _, ok := s.loaded.Load(node); ok {
+		return nil
+	}
+
+	var err error
+	if cluster, ok := conn.(*rdb.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *rdb.Client) error {
+			return client.ScriptLoad(ctx, s.src).Err()
+		})
+	} else {
+		err = conn.ScriptLoad(ctx, s.src).Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	s.loaded.Store(node, struct{}{})
+	return nil
+}
+
+// EvalScript runs the script with the keys and args, for the cluster type, the keys must be
+// in the same slot. Returns nil if the script returns nil.
+func (r *Redis) EvalScript(ctx context.Context, script *Script, keys []string,
+	args ...interface{}) (val interface{}, err error) {
+	err = r.do(func(conn RedisNode) error {
+		if err := script.load(ctx, r.resourceKey(), conn); err != nil {
+			return err
+		}
+
+		val, err = conn.EvalSha(ctx, script.hash, keys, args...).Result()
+		if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT ") {
+			val, err = conn.Eval(ctx, script.src, keys, args...).Result()
+		}
+		if err == rdb.Nil {
+			val = nil
+			return nil
+		}
+
+		return err
+	})
+
+	return
+}
+
+// DelIfEqual deletes the key only if its value equals val, returns false if not deleted.
+func (r *Redis) DelIfEqual(ctx context.Context, key, val string) (bool, error) {
+	resp, err := r.EvalScript(ctx, compareAndDeleteScript, []string{key}, val)
+	if err != nil {
+		return false, err
+	}
+
+	n, _ := resp.(int64)
+	return n == 1, nil
+}
+
+// GetOrSet returns the value of the key if exists, otherwise sets it to val with the expiry,
+// the check and the set are atomic. ok is false if the key didn't exist and val is set,
+// like setting a placeholder for the key being loaded.
+// The key never expires if expire <= 0, the same as Set.
+func (r *Redis) GetOrSet(ctx context.Context, key, val string, expire time.Duration) (
+	existing string, ok bool, err error) {
+	var millis int64
+	if expire > 0 {
+		// PX requires at least 1 millisecond
+		if millis = expire.Milliseconds(); millis == 0 {
+			millis = 1
+		}
+	}
+
+	resp, err := r.EvalScript(ctx, getOrSetScript, []string{key}, val, millis)
+	if err != nil {
+		return "", false, err
+	}
+	if resp == nil {
+		return "", false, nil
+	}
+
+	existing, ok = resp.(string)
+	return existing, ok, nil
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"redis-cache/hash"
+	"redis-cache/utils"
+	"time"
+)
+
+// transition keeps the ring before the membership changes, so that the keys moved to
+// the new owners can still be read from the previous owners during the transition window.
+type transition struct {
+	ring  hash.Balancer
+	until time.Time
+	// the nodes ejected or released in the window, they are not read as the previous owners
+	skipped map[string]bool
+}
+
+// beginTransition snapshots the current ring before the membership changes, must be called with cc.lock held.
+// If a transition is in progress, like adding several nodes in a row, the ring before all the changes is kept,
+// and the window is extended, otherwise the keys moved by the former changes would lose the previous owners.
+func (cc *cacheCluster) beginTransition() {
+	if cc.transitionWindow <= 0 {
+		return
+	}
+
+	if t, ok := cc.activeTransition(); ok {
+		cc.transition.Store(&transition{
+			ring:    t.ring,
+			until:   time.Now().Add(cc.transitionWindow),
+			skipped: t.skipped,
+		})
+		return
+	}
+
+	ring := cc.newBalancer()
+	for _, m := range cc.members {
+		if !m.ejected {
+			ring.AddWithWeight(m.node, m.weight)
+		}
+	}
+
+	cc.transition.Store(&transition{
+		ring:  ring,
+		until: time.Now().Add(cc.transitionWindow),
+	})
+}
+
+// skipInTransition stops reading the node as the previous owner in the transition window,
+// like the ejected nodes that are down, must be called with cc.lock held.
+func (cc *cacheCluster) skipInTransition(m *member) {
+	t, ok := cc.activeTransition()
+	if !ok {
+		return
+	}
+
+	skipped := map[string]bool{utils.Repr(m.node): true}
+	for name := range t.skipped {
+		skipped[name] = true
+	}
+	cc.transition.Store(&transition{
+		ring:    t.ring,
+		until:   t.until,
+		skipped: skipped,
+	})
+}
+
+func (cc *cacheCluster) activeTransition() (*transition, bool) {
+	t, _ := cc.transition.Load().(*transition)
+	if t == nil || time.Now().After(t.until) {
+		return nil, false
+	}
+
+	return t, true
+}
+
+// previousOwner returns the owner of the key before the last membership change,
+// ok is false if not in the transition window, the owner didn't change, or the previous owner is skipped.
+func (cc *cacheCluster) previousOwner(key string, current interface{}) (Cache, bool) {
+	t, ok := cc.activeTransition()
+	if !ok {
+		return nil, false
+	}
+
+	prev, ok := t.ring.Get(cc.dispatchKey(key))
+	if !ok || utils.Repr(prev) == utils.Repr(current) || t.skipped[utils.Repr(prev)] {
+		return nil, false
+	}
+
+	return prev.(Cache), true
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTransitionKeepsFirstRing(t *testing.T) {
+	cc := newTestCluster([]string{"a:6379", "b:6379"}, WithTransition(time.Minute))
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := "key#" + strconv.Itoa(i)
+		node, _ := cc.locate(key)
+		owners[key] = node.String()
+	}
+
+	// scale out with two nodes in a row
+	for _, host := range []string{"c:6379", "d:6379"} {
+		if err := cc.AddNode(NodeConf{Host: host, Type: NodeType, Weight: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var moved int
+	for key, owner := range owners {
+		node, _ := cc.locate(key)
+		if node.String() == owner {
+			if prev, ok := cc.previousOwner(key, node); ok {
+				t.Errorf("expected no previous owner of %q not moved, got %s", key, prev.(cacheNode).String())
+			}
+			continue
+		}
+
+		moved++
+		prev, ok := cc.previousOwner(key, node)
+		if !ok || prev.(cacheNode).String() != owner {
+			t.Errorf("expected the previous owner of %q to be %s before the scale out, got %v", key, owner, prev)
+		}
+	}
+	if moved == 0 {
+		t.Fatal("expected some keys moved to the added nodes")
+	}
+}
+
+func TestTransitionSkipsEjected(t *testing.T) {
+	cc := newTestCluster([]string{"a:6379", "b:6379", "c:6379"}, WithTransition(time.Minute))
+	if err := cc.AddNode(NodeConf{Host: "d:6379", Type: NodeType, Weight: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	cc.eject(cc.findMember("b:6379"), errTestPing)
+	for i := 0; i < 1000; i++ {
+		key := "key#" + strconv.Itoa(i)
+		node, _ := cc.locate(key)
+		if prev, ok := cc.previousOwner(key, node); ok && prev.(cacheNode).String() == "b:6379" {
+			t.Fatalf("expected the ejected node not read as the previous owner of %q", key)
+		}
+	}
+}
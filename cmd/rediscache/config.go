@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"redis-cache/cache"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultWeight = 100
+
+// loadConf loads the ClusterConf from a json or yaml file, decided by the file extension.
+func loadConf(file string) (cache.ClusterConf, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+	case ".yaml", ".yml":
+		if content, err = yamlToJson(content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file %q, only json and yaml are supported", file)
+	}
+
+	if content, err = parseDurations(content); err != nil {
+		return nil, fmt.Errorf("bad config file %q: %v", file, err)
+	}
+
+	var c cache.ClusterConf
+	if err = json.Unmarshal(content, &c); err != nil {
+		return nil, err
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no cache nodes in %q", file)
+	}
+
+	// the raw nodes to tell the absent keys from the zero values
+	var raw []map[string]json.RawMessage
+	if err = json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	// apply the defaults declared in the tags of cache.NodeConf
+	for i := range c {
+		if len(c[i].Type) == 0 {
+			c[i].Type = cache.NodeType
+		}
+		// an explicit weight 0 means no share, as NewCache does
+		if !hasKey(raw[i], "Weight") {
+			c[i].Weight = defaultWeight
+		}
+	}
+
+	return c, nil
+}
+
+// hasKey checks if the key is in m, case-insensitively as encoding/json matches the fields.
+func hasKey(m map[string]json.RawMessage, key string) bool {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findNode(c cache.ClusterConf, host string) (cache.NodeConf, bool) {
+	for _, node := range c {
+		if node.Host == host {
+			return node, true
+		}
+	}
+
+	return cache.NodeConf{}, false
+}
+
+// parseDurations converts the durations written as strings, like 2s, into nanoseconds,
+// so that the config files of the services can be loaded with encoding/json.
+func parseDurations(content []byte) ([]byte, error) {
+	var nodes []interface{}
+	if err := json.Unmarshal(content, &nodes); err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		val, err := parseDuration(nodes[i], reflect.TypeOf(cache.NodeConf{}))
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = val
+	}
+
+	return json.Marshal(nodes)
+}
+
+// parseDuration converts the string durations in val by the fields of t, matched case-insensitively.
+func parseDuration(val interface{}, t reflect.Type) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		if t != reflect.TypeOf(time.Duration(0)) {
+			return v, nil
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		return int64(d), nil
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return v, nil
+		}
+
+		for key, item := range v {
+			field, ok := t.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, key)
+			})
+			if !ok {
+				continue
+			}
+
+			parsed, err := parseDuration(item, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			v[key] = parsed
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// yamlToJson converts yaml to json, so that the keys are matched case-insensitively
+// as the json files, like Host and host.
+func yamlToJson(content []byte) ([]byte, error) {
+	var val interface{}
+	if err := yaml.Unmarshal(content, &val); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(toJsonValue(val))
+}
+
+// toJsonValue converts the map[interface{}]interface{} decoded by yaml to map[string]interface{}.
+func toJsonValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = toJsonValue(item)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = toJsonValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
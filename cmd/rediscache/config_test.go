@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"redis-cache/cache"
+	"testing"
+	"time"
+)
+
+func TestLoadConf(t *testing.T) {
+	c, err := loadConf("testdata/cache.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(c))
+	}
+
+	first := c[0]
+	if first.Host != "127.0.0.1:6379" || first.Type != cache.NodeType || first.Weight != defaultWeight {
+		t.Errorf("expected the defaults applied to the first node, got %+v", first)
+	}
+	if first.DialTimeout != 2*time.Second || first.ReadTimeout != 500*time.Millisecond {
+		t.Errorf("expected the timeouts 2s and 500ms, got %v and %v", first.DialTimeout, first.ReadTimeout)
+	}
+	if first.Breaker.Window != time.Minute || first.Breaker.Buckets != 60 {
+		t.Errorf("expected the breaker window 1m with 60 buckets, got %+v", first.Breaker)
+	}
+
+	second := c[1]
+	if second.Host != "127.0.0.1:6380" || second.Weight != 0 {
+		t.Errorf("expected the explicit weight 0 kept, got %+v", second)
+	}
+	if second.WriteTimeout != time.Second {
+		t.Errorf("expected the write timeout in nanoseconds, got %v", second.WriteTimeout)
+	}
+}
+
+func TestLoadConfBadDuration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rediscache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cache.json")
+	if err = ioutil.WriteFile(file, []byte(`[{"Host": "127.0.0.1:6379", "DialTimeout": "2 seconds"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = loadConf(file); err == nil {
+		t.Error("expected the bad duration to be rejected")
+	}
+}
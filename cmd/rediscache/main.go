@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"redis-cache/cache"
+	"redis-cache/hash"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSamples = 1000000
+
+var (
+	errUsage = errors.New("bad arguments")
+	hashTag  = flag.Bool("hashtag", false, "dispatch the keys by their {tag}s, as the cache with WithKeyExtractor(cache.HashTag)")
+	balancer = flag.String("balancer", hash.RingBalancer, "the balancer of the cache, ring, jump, rendezvous, maglev or ketama")
+	replicas = flag.Int("replicas", 1, "the number of the nodes that keep a key, as the cache with WithReplication(n)")
+)
+
+type command struct {
+	usage string
+	run   func(c cache.ClusterConf, args []string) error
+}
+
+var commands = map[string]command{
+	"locate":   {usage: "locate <key>...", run: locate},
+	"get":      {usage: "get <key>", run: get},
+	"del":      {usage: "del [-all] <key>...", run: del},
+	"ttl":      {usage: "ttl <key>", run: ttl},
+	"ring":     {usage: "ring [-samples n]", run: ring},
+	"export":   {usage: "export [-points]", run: export},
+	"probe":    {usage: "probe", run: probe},
+	"simulate": {usage: "simulate [-add host[:port]=weight] [-remove host] [-weight host=weight] [-samples n]", run: simulate},
+}
+
+func main() {
+	conf := flag.String("f", "cache.yaml", "the ClusterConf file, json or yaml")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := loadConf(*conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(c, flag.Args()[1:]); err == errUsage {
+		fmt.Fprintf(os.Stderr, "usage: rediscache %s\n", cmd.usage)
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: rediscache [-f cache.yaml] [-balancer ring] [-replicas 1] [-hashtag] <command> [args]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func locate(c cache.ClusterConf, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	dispatcher, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range args {
+		node, ok := dispatcher.Get(dispatchKey(key))
+		if !ok {
+			return fmt.Errorf("no node for key %q", key)
+		}
+
+		fmt.Printf("%s\t%s\n", key, node)
+	}
+
+	return nil
+}
+
+func get(c cache.ClusterConf, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	rds, err := ownerOf(c, args[0])
+	if err != nil {
+		return err
+	}
+
+	val, err := rds.Get(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(val)
+	return nil
+}
+
+// del deletes the keys from their owners, the primaries and the replicas, like DelCache.
+// The cache with bounded loads or in a transition window might keep a key on the other nodes,
+// which are not known from the config, use -all to delete the keys from all the nodes.
+func del(c cache.ClusterConf, args []string) error {
+	fs := flag.NewFlagSet("del", flag.ContinueOnError)
+	all := fs.Bool("all", false, "delete the keys from all the nodes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+
+	dispatcher, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return err
+	}
+
+	n := *replicas
+	if *all {
+		// no more nodes on the ring than in the config
+		n = len(c)
+	}
+
+	for _, key := range fs.Args() {
+		nodes, ok := dispatcher.GetN(dispatchKey(key), n)
+		if !ok || len(nodes) == 0 {
+			return fmt.Errorf("no node for key %q", key)
+		}
+
+		for _, node := range nodes {
+			rds := node.(cache.NodeConf).NewRedis()
+			if err = rds.Del(context.Background(), key); err != nil {
+				return err
+			}
+
+			fmt.Printf("%s\tdeleted from %s\n", key, rds)
+		}
+	}
+
+	return nil
+}
+
+func ttl(c cache.ClusterConf, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	rds, err := ownerOf(c, args[0])
+	if err != nil {
+		return err
+	}
+
+	val, err := rds.Ttl(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(val)
+	return nil
+}
+
+func ring(c cache.ClusterConf, args []string) error {
+	fs := flag.NewFlagSet("ring", flag.ContinueOnError)
+	samples := fs.Int("samples", defaultSamples, "the number of sampled keys, if the balancer is not ring")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// the nodes on the ring might be merged from c, so collect them from the ring
+	dispatcher, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[string]cache.NodeConf)
+	owned := make(map[string]float64)
+	if ring, ok := dispatcher.(*hash.ConsistentHash); ok {
+		// the exact shares of the hash space on the consistent hash ring
+		for _, share := range ring.Distribution() {
+			nc := share.Node.(cache.NodeConf)
+			nodes[nc.String()] = nc
+			owned[nc.String()] = share.Share
+		}
+	} else {
+		for i := 0; i < *samples; i++ {
+			node, ok := dispatcher.Get(sampleKey(i))
+			if !ok {
+				return fmt.Errorf("no nodes on the ring")
+			}
+
+			nc := node.(cache.NodeConf)
+			nodes[nc.String()] = nc
+			owned[nc.String()] += 1 / float64(*samples)
+		}
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%-24s %8s %8s\n", "NODE", "WEIGHT", "OWNED")
+	for _, name := range names {
+		fmt.Printf("%-24s %8d %7.2f%%\n", name, nodes[name].Weight, 100*owned[name])
+	}
+
+	return nil
+}
+
+func simulate(c cache.ClusterConf, args []string) error {
+	var adds, removes, weights multiFlag
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.Var(&adds, "add", "add a node, in the form of host=weight, can be repeated")
+	fs.Var(&removes, "remove", "remove a node by host, can be repeated")
+	fs.Var(&weights, "weight", "change the weight of a node, in the form of host=weight, can be repeated")
+	samples := fs.Int("samples", defaultSamples, "the number of sampled keys, if the balancer is not ring")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	nc, err := changeConf(c, adds, removes, weights)
+	if err != nil {
+		return err
+	}
+
+	before, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return err
+	}
+	after, err := cache.NewDispatcher(nc, *balancer)
+	if err != nil {
+		return err
+	}
+
+	if from, ok := before.(*hash.ConsistentHash); ok {
+		// the exact fraction of the hash space moved on the consistent hash ring
+		fmt.Printf("moved %.2f%% of the keys\n", 100*from.Diff(after.(*hash.ConsistentHash)))
+		return nil
+	}
+
+	var moved int
+	for i := 0; i < *samples; i++ {
+		key := sampleKey(i)
+		from, ok := before.Get(key)
+		if !ok {
+			return fmt.Errorf("no nodes on the ring")
+		}
+		to, ok := after.Get(key)
+		if !ok {
+			return fmt.Errorf("no nodes left on the ring")
+		}
+
+		if from.(cache.NodeConf).String() != to.(cache.NodeConf).String() {
+			moved++
+		}
+	}
+
+	fmt.Printf("moved %d of %d sampled keys, %.2f%%\n", moved, *samples, 100*float64(moved)/float64(*samples))
+	return nil
+}
+
+func export(c cache.ClusterConf, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	points := fs.Bool("points", false, "export the points on the ring")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dispatcher, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return err
+	}
+
+	ring, ok := dispatcher.(hash.Exportable)
+	if !ok {
+		return fmt.Errorf("balancer %q can't be exported, only ring and ketama can", *balancer)
+	}
+
+	content, err := json.MarshalIndent(ring.Spec(*points), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(content))
+	return nil
+}
+
+// probe prints the config with the nodes in the same redis cluster merged by CLUSTER NODES,
+// the printed config is meant to be saved and used by all the processes.
+func probe(c cache.ClusterConf, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	content, err := json.MarshalIndent(cache.ProbeClusters(c), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(content))
+	return nil
+}
+
+func changeConf(c cache.ClusterConf, adds, removes, weights []string) (cache.ClusterConf, error) {
+	nc := append(cache.ClusterConf(nil), c...)
+
+	for _, host := range removes {
+		if _, ok := findNode(nc, host); !ok {
+			return nil, fmt.Errorf("node %q not found", host)
+		}
+
+		var kept cache.ClusterConf
+		for _, node := range nc {
+			if node.Host != host {
+				kept = append(kept, node)
+			}
+		}
+		nc = kept
+	}
+
+	for _, add := range adds {
+		host, weight, err := parseHostWeight(add)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := findNode(nc, host); ok {
+			return nil, fmt.Errorf("node %q already exists", host)
+		}
+
+		nc = append(nc, cache.NodeConf{
+			Host:   host,
+			Type:   cache.NodeType,
+			Weight: weight,
+		})
+	}
+
+	for _, w := range weights {
+		host, weight, err := parseHostWeight(w)
+		if err != nil {
+			return nil, err
+		}
+
+		var found bool
+		for i := range nc {
+			if nc[i].Host == host {
+				nc[i].Weight = weight
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("node %q not found", host)
+		}
+	}
+
+	return nc, nil
+}
+
+func ownerOf(c cache.ClusterConf, key string) (*cache.Redis, error) {
+	dispatcher, err := cache.NewDispatcher(c, *balancer)
+	if err != nil {
+		return nil, err
+	}
+
+	node, ok := dispatcher.Get(dispatchKey(key))
+	if !ok {
+		return nil, fmt.Errorf("no node for key %q", key)
+	}
+
+	return node.(cache.NodeConf).NewRedis(), nil
+}
+
+// dispatchKey returns the part of the key that the cache dispatches it by.
+func dispatchKey(key string) string {
+	if *hashTag {
+		return cache.HashTag(key)
+	}
+
+	return key
+}
+
+func parseHostWeight(s string) (string, int, error) {
+	pos := strings.LastIndex(s, "=")
+	if pos <= 0 {
+		return s, defaultWeight, nil
+	}
+
+	weight, err := strconv.Atoi(s[pos+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("bad weight in %q: %v", s, err)
+	}
+
+	return s[:pos], weight, nil
+}
+
+func sampleKey(i int) string {
+	return "rediscache#sample#" + strconv.Itoa(i)
+}
+
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
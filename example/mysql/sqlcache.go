@@ -157,34 +157,10 @@ func main() {
 	// 		Weight: 100,
 	// 	},
 	// }
+	// 同一个 redis cluster 只需配置一个节点，多个种子地址用逗号分隔
 	cf := cache.ClusterConf{
 		cache.NodeConf{
-			Host:   "127.0.0.1:7000",
-			Type:   "cluster",
-			Weight: 100,
-		},
-		cache.NodeConf{
-			Host:   "127.0.0.1:7001",
-			Type:   "cluster",
-			Weight: 100,
-		},
-		cache.NodeConf{
-			Host:   "127.0.0.1:7002",
-			Type:   "cluster",
-			Weight: 100,
-		},
-		cache.NodeConf{
-			Host:   "127.0.0.1:7003",
-			Type:   "cluster",
-			Weight: 100,
-		},
-		cache.NodeConf{
-			Host:   "127.0.0.1:7004",
-			Type:   "cluster",
-			Weight: 100,
-		},
-		cache.NodeConf{
-			Host:   "127.0.0.1:7005",
+			Host:   "127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002,127.0.0.1:7003,127.0.0.1:7004,127.0.0.1:7005",
 			Type:   "cluster",
 			Weight: 100,
 		},
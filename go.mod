@@ -3,8 +3,10 @@ module redis-cache
 go 1.15
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/go-redis/redis/v8 v8.7.1
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jmoiron/sqlx v1.3.1
 	github.com/spaolacci/murmur3 v1.1.0
+	gopkg.in/yaml.v2 v2.4.0
 )
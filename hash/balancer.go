@@ -0,0 +1,124 @@
+package hash
+
+import "fmt"
+
+const (
+	// RingBalancer is the consistent hash ring with virtual nodes, the default one.
+	RingBalancer = "ring"
+	// JumpBalancer is the jump consistent hash, the weight is the number of buckets of the node.
+	JumpBalancer = "jump"
+	// RendezvousBalancer is the weighted rendezvous (highest random weight) hash.
+	RendezvousBalancer = "rendezvous"
+	// MaglevBalancer is the maglev hash with a fixed size lookup table.
+	MaglevBalancer = "maglev"
+	// KetamaBalancer is the weighted ketama ring compatible with libmemcached.
+	KetamaBalancer = "ketama"
+)
+
+type (
+	// Balancer selects the nodes for the keys.
+	Balancer interface {
+		// AddWithWeight adds the node with the weight, relative to TopWeight,
+		// the later call will overwrite the weight of the former calls.
+		AddWithWeight(node interface{}, weight int)
+		// Remove removes the node.
+		Remove(node interface{})
+		// Get returns the node of v.
+		Get(v interface{}) (interface{}, bool)
+		// GetN returns at most n distinct nodes of v, the first one is the same as Get.
+		GetN(v interface{}, n int) ([]interface{}, bool)
+	}
+
+	// LoadBalancer is a Balancer that skips the overloaded nodes, the loads are reported by Inc and Done.
+	LoadBalancer interface {
+		Balancer
+		// SetLoadBound enables the bounded-load mode if epsilon is positive.
+		SetLoadBound(epsilon float64)
+		// Inc increases the load of the node by one.
+		Inc(node interface{})
+		// Done decreases the load of the node by one.
+		Done(node interface{})
+	}
+
+	// BalancerFactory creates empty balancers of the same algorithm.
+	BalancerFactory func() Balancer
+
+	weightedNode struct {
+		node   interface{}
+		repr   string
+		weight int
+	}
+)
+
+var _ LoadBalancer = (*ConsistentHash)(nil)
+
+// NewBalancer returns an empty balancer of the algorithm, empty name means RingBalancer.
+func NewBalancer(name string) (Balancer, error) {
+	factory, err := NewBalancerFactory(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(), nil
+}
+
+// NewBalancerFactory returns the factory of the balancers of the algorithm, empty name means RingBalancer.
+func NewBalancerFactory(name string) (BalancerFactory, error) {
+	switch name {
+	case "", RingBalancer:
+		return func() Balancer {
+			return NewConsistentHash()
+		}, nil
+	case JumpBalancer:
+		return func() Balancer {
+			return NewJumpHash()
+		}, nil
+	case RendezvousBalancer:
+		return func() Balancer {
+			return NewRendezvousHash()
+		}, nil
+	case MaglevBalancer:
+		return func() Balancer {
+			return NewMaglevHash()
+		}, nil
+	case KetamaBalancer:
+		return func() Balancer {
+			return NewKetamaHash()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown balancer %q", name)
+	}
+}
+
+// setWeighted adds the node or changes its weight, the position of an existing node is kept.
+func setWeighted(nodes []weightedNode, node interface{}, weight int) []weightedNode {
+	if weight < 0 {
+		weight = 0
+	}
+
+	nodeRepr := repr(node)
+	for i := range nodes {
+		if nodes[i].repr == nodeRepr {
+			nodes[i].node = node
+			nodes[i].weight = weight
+			return nodes
+		}
+	}
+
+	return append(nodes, weightedNode{
+		node:   node,
+		repr:   nodeRepr,
+		weight: weight,
+	})
+}
+
+func removeWeighted(nodes []weightedNode, node interface{}) []weightedNode {
+	nodeRepr := repr(node)
+	for i := range nodes {
+		if nodes[i].repr == nodeRepr {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
+
+	return nodes
+}
@@ -0,0 +1,214 @@
+package hash
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+const sampleKeys = 100000
+
+var sampledBalancers = []string{JumpBalancer, RendezvousBalancer, MaglevBalancer}
+
+func newSampledBalancer(t *testing.T, name string, nodes ...string) Balancer {
+	h, err := NewBalancer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes {
+		h.AddWithWeight(node, TopWeight)
+	}
+
+	return h
+}
+
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = "node#" + strconv.Itoa(i)
+	}
+
+	return nodes
+}
+
+// owners returns the owner of each of the sampled keys.
+func owners(t *testing.T, h Balancer) []interface{} {
+	result := make([]interface{}, sampleKeys)
+	for i := range result {
+		node, ok := h.Get("key#" + strconv.Itoa(i))
+		if !ok {
+			t.Fatal("expected a node for the key")
+		}
+		result[i] = node
+	}
+
+	return result
+}
+
+// moved returns the ratio of the sampled keys that changed owners, and the ratio of them
+// that were moved between the nodes other than the changed one.
+func moved(from, to []interface{}, changed string) (float64, float64) {
+	var all, others int
+	for i := range from {
+		if from[i] == to[i] {
+			continue
+		}
+		all++
+		if from[i] != changed && to[i] != changed {
+			others++
+		}
+	}
+
+	return float64(all) / sampleKeys, float64(others) / sampleKeys
+}
+
+func TestBalancersDistribution(t *testing.T) {
+	const nodes = 10
+	for _, name := range sampledBalancers {
+		counts := make(map[interface{}]int)
+		for _, node := range owners(t, newSampledBalancer(t, name, nodeNames(nodes)...)) {
+			counts[node]++
+		}
+
+		if len(counts) != nodes {
+			t.Fatalf("%s: expected keys on %d nodes, got %d", name, nodes, len(counts))
+		}
+		for node, count := range counts {
+			share := float64(count) / sampleKeys
+			if math.Abs(share*nodes-1) > 0.1 {
+				t.Errorf("%s: share of %v is %.4f, expected about %.4f", name, node, share, 1.0/nodes)
+			}
+		}
+	}
+}
+
+func TestBalancersWeighted(t *testing.T) {
+	for _, name := range sampledBalancers {
+		h, err := NewBalancer(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.AddWithWeight("heavy", 2*TopWeight)
+		h.AddWithWeight("light", TopWeight)
+		h.AddWithWeight("none", 0)
+
+		counts := make(map[interface{}]int)
+		for _, node := range owners(t, h) {
+			counts[node]++
+		}
+
+		if counts["none"] != 0 {
+			t.Errorf("%s: expected no keys on the node without weight, got %d", name, counts["none"])
+		}
+		if ratio := float64(counts["heavy"]) / float64(counts["light"]); math.Abs(ratio-2) > 0.2 {
+			t.Errorf("%s: expected the heavy node to own about 2 times the light one, got %.2f", name, ratio)
+		}
+	}
+}
+
+func TestBalancersDeterministic(t *testing.T) {
+	nodes := nodeNames(8)
+	reversed := make([]string, len(nodes))
+	for i, node := range nodes {
+		reversed[len(nodes)-1-i] = node
+	}
+
+	for _, name := range sampledBalancers {
+		expect := owners(t, newSampledBalancer(t, name, nodes...))
+		if diff, _ := moved(expect, owners(t, newSampledBalancer(t, name, nodes...)), ""); diff != 0 {
+			t.Errorf("%s: expected the same owners on the same nodes, %.4f moved", name, diff)
+		}
+
+		// the buckets of jump hash follow the order the nodes are added in
+		if name == JumpBalancer {
+			continue
+		}
+		if diff, _ := moved(expect, owners(t, newSampledBalancer(t, name, reversed...)), ""); diff != 0 {
+			t.Errorf("%s: expected the owners not to depend on the order of the nodes, %.4f moved", name, diff)
+		}
+	}
+}
+
+func TestBalancersGetN(t *testing.T) {
+	for _, name := range sampledBalancers {
+		h := newSampledBalancer(t, name, nodeNames(5)...)
+		for i := 0; i < 1000; i++ {
+			key := "key#" + strconv.Itoa(i)
+			primary, _ := h.Get(key)
+			nodes, ok := h.GetN(key, 3)
+			if !ok || len(nodes) != 3 {
+				t.Fatalf("%s: expected 3 nodes, got %v", name, nodes)
+			}
+			if nodes[0] != primary {
+				t.Fatalf("%s: expected the first node %v to be the primary %v", name, nodes[0], primary)
+			}
+			if nodes[0] == nodes[1] || nodes[0] == nodes[2] || nodes[1] == nodes[2] {
+				t.Fatalf("%s: expected distinct nodes, got %v", name, nodes)
+			}
+		}
+	}
+}
+
+func TestBalancersAddNode(t *testing.T) {
+	const nodes = 10
+	added := "node#" + strconv.Itoa(nodes)
+	for _, name := range sampledBalancers {
+		h := newSampledBalancer(t, name, nodeNames(nodes)...)
+		from := owners(t, h)
+		h.AddWithWeight(added, TopWeight)
+
+		diff, others := moved(from, owners(t, h), added)
+		expect := 1.0 / (nodes + 1)
+		if math.Abs(diff/expect-1) > 0.15 {
+			t.Errorf("%s: adding a node moved %.4f, expected about %.4f", name, diff, expect)
+		}
+		// maglev moves a few keys between the other nodes when the table is refilled
+		if others > 0.01 {
+			t.Errorf("%s: adding a node moved %.4f between the other nodes", name, others)
+		}
+	}
+}
+
+func TestBalancersRemoveNode(t *testing.T) {
+	const nodes = 10
+	for _, name := range []string{RendezvousBalancer, MaglevBalancer} {
+		for _, removed := range []string{"node#0", "node#5", "node#9"} {
+			h := newSampledBalancer(t, name, nodeNames(nodes)...)
+			from := owners(t, h)
+			h.Remove(removed)
+
+			diff, others := moved(from, owners(t, h), removed)
+			expect := 1.0 / nodes
+			if math.Abs(diff/expect-1) > 0.15 {
+				t.Errorf("%s: removing %s moved %.4f, expected about %.4f", name, removed, diff, expect)
+			}
+			if others > 0.01 {
+				t.Errorf("%s: removing %s moved %.4f between the other nodes", name, removed, others)
+			}
+		}
+	}
+}
+
+func TestJumpRemoveNode(t *testing.T) {
+	const nodes = 10
+	expect := 1.0 / nodes
+
+	// removing the last node only moves its own keys
+	h := newSampledBalancer(t, JumpBalancer, nodeNames(nodes)...)
+	from := owners(t, h)
+	h.Remove("node#9")
+	diff, others := moved(from, owners(t, h), "node#9")
+	if math.Abs(diff/expect-1) > 0.15 || others != 0 {
+		t.Errorf("removing the last node moved %.4f, %.4f between the other nodes, expected about %.4f",
+			diff, others, expect)
+	}
+
+	// removing a node in the middle shifts the buckets of the nodes after it,
+	// which is why the jump balancer can't be used with the health check
+	h = newSampledBalancer(t, JumpBalancer, nodeNames(nodes)...)
+	from = owners(t, h)
+	h.Remove("node#0")
+	if diff, _ = moved(from, owners(t, h), "node#0"); diff < 3*expect {
+		t.Errorf("removing the first node moved %.4f, expected far more than %.4f", diff, expect)
+	}
+}
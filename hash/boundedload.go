@@ -0,0 +1,101 @@
+package hash
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// SetLoadBound enables the bounded-load mode (Mirrokni et al.) if epsilon is positive, Get skips
+// the nodes with the load above (1+epsilon) times their share of the total load, and walks
+// to the next point on the ring. The loads are reported by Inc/Done or UpdateLoad.
+// The share of a node is proportional to its number of points, that is its weight.
+func (h *ConsistentHash) SetLoadBound(epsilon float64) {
+	h.update(func(s *ringState) {
+		s.loadBound = epsilon
+	})
+}
+
+// Inc increases the load of the node by one, like starting a request on it.
+func (h *ConsistentHash) Inc(node interface{}) {
+	h.addLoad(repr(node), 1)
+}
+
+// Done decreases the load of the node by one, like finishing a request on it.
+func (h *ConsistentHash) Done(node interface{}) {
+	h.addLoad(repr(node), -1)
+}
+
+// UpdateLoad sets the load of the node, like the recent requests per second on it.
+func (h *ConsistentHash) UpdateLoad(node interface{}, load int64) {
+	if val, ok := h.load().loads[repr(node)]; ok {
+		atomic.AddInt64(&h.totalLoad, load-atomic.SwapInt64(val, load))
+	}
+}
+
+// Load returns the load of the node.
+func (h *ConsistentHash) Load(node interface{}) int64 {
+	if load, ok := h.load().loads[repr(node)]; ok {
+		return atomic.LoadInt64(load)
+	}
+
+	return 0
+}
+
+func (h *ConsistentHash) addLoad(nodeRepr string, delta int64) {
+	load, ok := h.load().loads[nodeRepr]
+	if !ok {
+		return
+	}
+
+	// the load might be dropped by Remove before Done, don't let it go negative
+	if atomic.AddInt64(load, delta) < 0 && delta < 0 {
+		atomic.AddInt64(load, -delta)
+		return
+	}
+	atomic.AddInt64(&h.totalLoad, delta)
+}
+
+// getBounded walks the ring clockwise from index, returns the first node that is not overloaded,
+// or the node of index if all the nodes are overloaded.
+func (h *ConsistentHash) getBounded(s *ringState, v interface{}, index int) (interface{}, bool) {
+	// one more for the request to be dispatched
+	total := float64(atomic.LoadInt64(&h.totalLoad) + 1)
+	overloaded := make(map[string]bool)
+	within := func(node interface{}) bool {
+		nodeRepr := repr(node)
+		over, ok := overloaded[nodeRepr]
+		if !ok {
+			var load int64
+			if val, ok := s.loads[nodeRepr]; ok {
+				load = atomic.LoadInt64(val)
+			}
+			share := float64(s.points[nodeRepr]) / float64(len(s.keys))
+			over = float64(load+1) > math.Ceil((1+s.loadBound)*total*share)
+			overloaded[nodeRepr] = over
+		}
+		return !over
+	}
+
+	var first interface{}
+	for i := 0; i < len(s.keys) && len(overloaded) < len(s.nodes); i++ {
+		nodes := s.ring[s.keys[(index+i)%len(s.keys)]]
+		if len(nodes) == 0 {
+			continue
+		}
+
+		picked := nodes[h.pick(nodes, v)]
+		if first == nil {
+			first = picked
+		}
+		if within(picked) {
+			return picked, true
+		}
+		for _, node := range nodes {
+			if within(node) {
+				return node, true
+			}
+		}
+	}
+
+	return first, first != nil
+}
@@ -2,15 +2,18 @@ package hash
 
 import (
 	"fmt"
+	"math"
 	"redis-cache/utils"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/spaolacci/murmur3"
 )
 
 const (
+	// TopWeight is the weight of the nodes added by Add, the nodes can be weighted above it.
 	TopWeight = 100
 
 	minReplicas = 100
@@ -21,13 +24,30 @@ type (
 	HashFunc        func(data []byte) uint64
 	PlaceholderType = struct{}
 
+	// ConsistentHash is the consistent hash ring with virtual nodes, the lookups take no locks,
+	// they read the immutable snapshot of the ring, which is rebuilt and replaced on changes.
 	ConsistentHash struct {
-		hashFunc HashFunc
-		replicas int
-		keys     []uint64
-		ring     map[uint64][]interface{}
-		nodes    map[string]PlaceholderType
-		lock     sync.RWMutex
+		// the sum of the loads, accessed atomically, keep it first to be 64-bit aligned
+		totalLoad int64
+		hashFunc  HashFunc
+		hashName  string
+		replicas  int
+		// the *ringState, never changed after stored
+		state atomic.Value
+		// serializes the changes
+		lock sync.Mutex
+	}
+
+	// ringState is the snapshot of the ring, copied on write.
+	ringState struct {
+		keys  []uint64
+		ring  map[uint64][]interface{}
+		nodes map[string]PlaceholderType
+		// the number of points and the load of each node, used by the bounded-load mode,
+		// the loads are shared by the snapshots, and changed atomically
+		points    map[string]int
+		loads     map[string]*int64
+		loadBound float64
 	}
 )
 
@@ -38,7 +58,7 @@ func Hash(data []byte) uint64 {
 }
 
 func NewConsistentHash() *ConsistentHash {
-	return NewCustomConsistentHash(minReplicas, Hash)
+	return NewCustomConsistentHash(minReplicas, nil)
 }
 
 func NewCustomConsistentHash(replicas int, fn HashFunc) *ConsistentHash {
@@ -46,16 +66,26 @@ func NewCustomConsistentHash(replicas int, fn HashFunc) *ConsistentHash {
 		replicas = minReplicas
 	}
 
+	// the name of the hash in the exported specs, unknown for the custom ones
+	var hashName string
 	if fn == nil {
 		fn = Hash
+		hashName = Murmur3
 	}
 
-	return &ConsistentHash{
+	h := &ConsistentHash{
 		hashFunc: fn,
+		hashName: hashName,
 		replicas: replicas,
-		ring:     make(map[uint64][]interface{}),
-		nodes:    make(map[string]PlaceholderType),
 	}
+	h.state.Store(&ringState{
+		ring:   make(map[uint64][]interface{}),
+		nodes:  make(map[string]PlaceholderType),
+		points: make(map[string]int),
+		loads:  make(map[string]*int64),
+	})
+
+	return h
 }
 
 // Add adds the node with the number of h.replicas,
@@ -65,114 +95,227 @@ func (h *ConsistentHash) Add(node interface{}) {
 }
 
 // AddWithReplicas adds the node with the number of replicas,
-// replicas will be truncated to h.replicas if it's larger than h.replicas,
 // the later call will overwrite the replicas of the former calls.
 func (h *ConsistentHash) AddWithReplicas(node interface{}, replicas int) {
-	h.Remove(node)
-
-	if replicas > h.replicas {
-		replicas = h.replicas
+	if replicas < 0 {
+		replicas = 0
 	}
 
 	nodeRepr := repr(node)
-	h.lock.Lock()
-	defer h.lock.Unlock()
-	h.addNode(nodeRepr)
+	h.update(func(s *ringState) {
+		// keep the load of the node, only the points are replaced
+		h.remove(s, nodeRepr)
+		s.nodes[nodeRepr] = Placeholder
+		s.points[nodeRepr] = replicas
+		if _, ok := s.loads[nodeRepr]; !ok {
+			s.loads[nodeRepr] = new(int64)
+		}
 
-	for i := 0; i < replicas; i++ {
-		hash := h.hashFunc([]byte(nodeRepr + strconv.Itoa(i)))
-		h.keys = append(h.keys, hash)
-		h.ring[hash] = append(h.ring[hash], node)
-	}
+		for i := 0; i < replicas; i++ {
+			hash := h.hashFunc([]byte(nodeRepr + strconv.Itoa(i)))
+			s.keys = append(s.keys, hash)
+			// copy the nodes on write, they might be shared with the former snapshots
+			nodes := s.ring[hash]
+			s.ring[hash] = append(nodes[:len(nodes):len(nodes)], node)
+		}
 
-	sort.Slice(h.keys, func(i int, j int) bool {
-		return h.keys[i] < h.keys[j]
+		sort.Slice(s.keys, func(i int, j int) bool {
+			return s.keys[i] < s.keys[j]
+		})
 	})
 }
 
-// AddWithWeight 添加节点时配置权重，可选择范围为 1~100 ，表示权重的百分比
+// AddWithWeight 添加节点时配置权重，权重是相对于 TopWeight 的比例，不设上限，
+// 例如权重 400 的节点分到的 key 是权重 100 的节点的 4 倍
 func (h *ConsistentHash) AddWithWeight(node interface{}, weight int) {
 	replicas := h.replicas * weight / TopWeight
 	h.AddWithReplicas(node, replicas)
 }
 
-func (h *ConsistentHash) Get(v interface{}) (interface{}, bool) {
-	h.lock.RLock()
-	defer h.lock.RUnlock()
+// AddWithFloatWeight adds the node with the fractional weight relative to TopWeight,
+// the number of replicas is rounded to the nearest integer, and any positive weight takes at least one.
+func (h *ConsistentHash) AddWithFloatWeight(node interface{}, weight float64) {
+	replicas := math.Round(float64(h.replicas) * weight / TopWeight)
+	if weight > 0 && replicas < 1 {
+		replicas = 1
+	}
+	h.AddWithReplicas(node, int(replicas))
+}
 
-	if len(h.ring) == 0 {
+func (h *ConsistentHash) Get(v interface{}) (interface{}, bool) {
+	s := h.load()
+	if len(s.ring) == 0 {
 		return nil, false
 	}
 
-	hash := h.hashFunc([]byte(repr(v)))
-	index := sort.Search(len(h.keys), func(i int) bool {
-		return h.keys[i] >= hash
-	}) % len(h.keys)
+	index := h.index(s, v)
+	if s.loadBound > 0 {
+		return h.getBounded(s, v, index)
+	}
 
-	nodes := h.ring[h.keys[index]]
+	nodes := s.ring[s.keys[index]]
 	switch len(nodes) {
 	case 0:
 		return nil, false
 	case 1:
 		return nodes[0], true
 	default:
-		innerIndex := h.hashFunc([]byte(innerRepr(v)))
-		pos := int(innerIndex % uint64(len(nodes)))
-		return nodes[pos], true
+		return nodes[h.pick(nodes, v)], true
 	}
 }
 
+// GetN returns at most n distinct nodes for v by walking the ring clockwise,
+// the first node is the same as the one returned by Get if not in the bounded-load mode,
+// the loads are not considered by GetN.
+func (h *ConsistentHash) GetN(v interface{}, n int) ([]interface{}, bool) {
+	s := h.load()
+	if len(s.ring) == 0 || n <= 0 {
+		return nil, false
+	}
+
+	if n > len(s.nodes) {
+		n = len(s.nodes)
+	}
+
+	index := h.index(s, v)
+	nodes := make([]interface{}, 0, n)
+	seen := make(map[string]PlaceholderType, n)
+	add := func(node interface{}) {
+		nodeRepr := repr(node)
+		if _, ok := seen[nodeRepr]; !ok {
+			seen[nodeRepr] = Placeholder
+			nodes = append(nodes, node)
+		}
+	}
+
+	for i := 0; i < len(s.keys) && len(nodes) < n; i++ {
+		candidates := s.ring[s.keys[(index+i)%len(s.keys)]]
+		// the collided nodes on the first point, the one picked by Get goes first
+		if i == 0 && len(candidates) > 1 {
+			add(candidates[h.pick(candidates, v)])
+		}
+		for _, node := range candidates {
+			if len(nodes) < n {
+				add(node)
+			}
+		}
+	}
+
+	return nodes, true
+}
+
+// index returns the index of the first point on the ring that v falls into.
+func (h *ConsistentHash) index(s *ringState, v interface{}) int {
+	hash := h.hashFunc([]byte(repr(v)))
+	return sort.Search(len(s.keys), func(i int) bool {
+		return s.keys[i] >= hash
+	}) % len(s.keys)
+}
+
 func (h *ConsistentHash) Remove(node interface{}) {
 	nodeRepr := repr(node)
+	h.update(func(s *ringState) {
+		h.remove(s, nodeRepr)
+		if load, ok := s.loads[nodeRepr]; ok {
+			atomic.AddInt64(&h.totalLoad, -atomic.LoadInt64(load))
+			delete(s.loads, nodeRepr)
+		}
+	})
+}
+
+// load returns the current snapshot of the ring, which must not be changed.
+func (h *ConsistentHash) load() *ringState {
+	return h.state.Load().(*ringState)
+}
 
+// update changes a copy of the current snapshot by fn, and replaces the current one with it.
+func (h *ConsistentHash) update(fn func(s *ringState)) {
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
-	if !h.containsNode(nodeRepr) {
+	s := h.load().clone()
+	fn(s)
+	h.state.Store(s)
+}
+
+// remove removes the points of the node from s.
+func (h *ConsistentHash) remove(s *ringState, nodeRepr string) {
+	if !s.containsNode(nodeRepr) {
 		return
 	}
 
-	for i := 0; i < h.replicas; i++ {
+	// the points are removed by the number of them when added, the node might be weighted above TopWeight
+	for i := 0; i < s.points[nodeRepr]; i++ {
 		hash := h.hashFunc([]byte(nodeRepr + strconv.Itoa(i)))
-		index := sort.Search(len(h.keys), func(i int) bool {
-			return h.keys[i] >= hash
+		index := sort.Search(len(s.keys), func(i int) bool {
+			return s.keys[i] >= hash
 		})
-		if index < len(h.keys) {
-			h.keys = append(h.keys[:index], h.keys[index+1:]...)
+		if index < len(s.keys) && s.keys[index] == hash {
+			s.keys = append(s.keys[:index], s.keys[index+1:]...)
 		}
-		h.removeRingNode(hash, nodeRepr)
+		s.removeRingNode(hash, nodeRepr)
 	}
 
-	h.removeNode(nodeRepr)
+	s.removeNode(nodeRepr)
 }
 
-func (h *ConsistentHash) removeRingNode(hash uint64, nodeRepr string) {
-	if nodes, ok := h.ring[hash]; ok {
-		newNodes := nodes[:0]
+// clone copies the snapshot, the keys are copied, so that they can be changed in place,
+// but the nodes on the points are shared, and must be copied on write.
+func (s *ringState) clone() *ringState {
+	ns := &ringState{
+		keys:      append([]uint64(nil), s.keys...),
+		ring:      make(map[uint64][]interface{}, len(s.ring)),
+		nodes:     make(map[string]PlaceholderType, len(s.nodes)),
+		points:    make(map[string]int, len(s.points)),
+		loads:     make(map[string]*int64, len(s.loads)),
+		loadBound: s.loadBound,
+	}
+	for k, v := range s.ring {
+		ns.ring[k] = v
+	}
+	for k, v := range s.nodes {
+		ns.nodes[k] = v
+	}
+	for k, v := range s.points {
+		ns.points[k] = v
+	}
+	for k, v := range s.loads {
+		ns.loads[k] = v
+	}
+
+	return ns
+}
+
+func (s *ringState) removeRingNode(hash uint64, nodeRepr string) {
+	if nodes, ok := s.ring[hash]; ok {
+		newNodes := make([]interface{}, 0, len(nodes))
 		for _, x := range nodes {
 			if repr(x) != nodeRepr {
 				newNodes = append(newNodes, x)
 			}
 		}
 		if len(newNodes) > 0 {
-			h.ring[hash] = newNodes
+			s.ring[hash] = newNodes
 		} else {
-			delete(h.ring, hash)
+			delete(s.ring, hash)
 		}
 	}
 }
 
-func (h *ConsistentHash) addNode(nodeRepr string) {
-	h.nodes[nodeRepr] = Placeholder
+func (s *ringState) containsNode(nodeRepr string) bool {
+	_, ok := s.nodes[nodeRepr]
+	return ok
 }
 
-func (h *ConsistentHash) containsNode(nodeRepr string) bool {
-	_, ok := h.nodes[nodeRepr]
-	return ok
+func (s *ringState) removeNode(nodeRepr string) {
+	delete(s.nodes, nodeRepr)
+	delete(s.points, nodeRepr)
 }
 
-func (h *ConsistentHash) removeNode(nodeRepr string) {
-	delete(h.nodes, nodeRepr)
+// pick picks one of the nodes collided on the same point for v, by the hash func of the ring.
+func (h *ConsistentHash) pick(nodes []interface{}, v interface{}) int {
+	innerIndex := h.hashFunc([]byte(innerRepr(v)))
+	return int(innerIndex % uint64(len(nodes)))
 }
 
 func innerRepr(node interface{}) string {
@@ -0,0 +1,222 @@
+package hash
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	benchNodes = 10
+	benchKeys  = 1024
+)
+
+type (
+	getter interface {
+		Get(v interface{}) (interface{}, bool)
+		AddWithWeight(node interface{}, weight int)
+	}
+
+	// rwMutexHash is a copy of the ring before the snapshots as the baseline, the lookups hold
+	// the read lock, and the changes hold the write lock while the points are replaced in place.
+	rwMutexHash struct {
+		replicas int
+		keys     []uint64
+		ring     map[uint64][]interface{}
+		points   map[string]int
+		lock     sync.RWMutex
+	}
+)
+
+func newRWMutexHash() *rwMutexHash {
+	h := &rwMutexHash{
+		replicas: minReplicas,
+		ring:     make(map[uint64][]interface{}),
+		points:   make(map[string]int),
+	}
+	for i := 0; i < benchNodes; i++ {
+		h.AddWithWeight("node#"+strconv.Itoa(i), TopWeight)
+	}
+
+	return h
+}
+
+func (h *rwMutexHash) Get(v interface{}) (interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.ring) == 0 {
+		return nil, false
+	}
+
+	hash := Hash([]byte(repr(v)))
+	index := sort.Search(len(h.keys), func(i int) bool {
+		return h.keys[i] >= hash
+	}) % len(h.keys)
+
+	nodes := h.ring[h.keys[index]]
+	switch len(nodes) {
+	case 0:
+		return nil, false
+	case 1:
+		return nodes[0], true
+	default:
+		innerIndex := Hash([]byte(innerRepr(v)))
+		return nodes[int(innerIndex%uint64(len(nodes)))], true
+	}
+}
+
+func (h *rwMutexHash) AddWithWeight(node interface{}, weight int) {
+	replicas := h.replicas * weight / TopWeight
+	nodeRepr := repr(node)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.remove(nodeRepr)
+	h.points[nodeRepr] = replicas
+	for i := 0; i < replicas; i++ {
+		hash := Hash([]byte(nodeRepr + strconv.Itoa(i)))
+		h.keys = append(h.keys, hash)
+		h.ring[hash] = append(h.ring[hash], node)
+	}
+
+	sort.Slice(h.keys, func(i int, j int) bool {
+		return h.keys[i] < h.keys[j]
+	})
+}
+
+func (h *rwMutexHash) remove(nodeRepr string) {
+	for i := 0; i < h.points[nodeRepr]; i++ {
+		hash := Hash([]byte(nodeRepr + strconv.Itoa(i)))
+		index := sort.Search(len(h.keys), func(i int) bool {
+			return h.keys[i] >= hash
+		})
+		if index < len(h.keys) && h.keys[index] == hash {
+			h.keys = append(h.keys[:index], h.keys[index+1:]...)
+		}
+
+		nodes := h.ring[hash][:0]
+		for _, x := range h.ring[hash] {
+			if repr(x) != nodeRepr {
+				nodes = append(nodes, x)
+			}
+		}
+		if len(nodes) > 0 {
+			h.ring[hash] = nodes
+		} else {
+			delete(h.ring, hash)
+		}
+	}
+
+	delete(h.points, nodeRepr)
+}
+
+func TestRWMutexHashSameAsRing(t *testing.T) {
+	baseline, ring := newRWMutexHash(), newBenchHash()
+	baseline.AddWithWeight("node#0", TopWeight/2)
+	ring.AddWithWeight("node#0", TopWeight/2)
+
+	// the baseline must pick the same nodes, or the benchmark compares different lookups
+	for i := 0; i < benchKeys; i++ {
+		key := "key#" + strconv.Itoa(i)
+		expect, _ := ring.Get(key)
+		if node, ok := baseline.Get(key); !ok || node != expect {
+			t.Fatalf("expected %v for %s, got %v", expect, key, node)
+		}
+	}
+}
+
+func BenchmarkConsistentHashGet(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		ring func() getter
+	}{
+		{
+			name: "lockfree",
+			ring: func() getter {
+				return newBenchHash()
+			},
+		},
+		{
+			name: "rwmutex",
+			ring: func() getter {
+				return newRWMutexHash()
+			},
+		},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			benchmarkGet(b, bm.ring(), false)
+		})
+		b.Run(bm.name+"-changing", func(b *testing.B) {
+			benchmarkGet(b, bm.ring(), true)
+		})
+	}
+}
+
+// benchmarkGet looks up the keys in parallel, and reweights a node every millisecond if changing.
+func benchmarkGet(b *testing.B, ring getter, changing bool) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key#" + strconv.Itoa(i)
+	}
+
+	var done int32
+	var wg sync.WaitGroup
+	if changing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; atomic.LoadInt32(&done) == 0; i++ {
+				ring.AddWithWeight("node#0", TopWeight/2+i%2*TopWeight/2)
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			if _, ok := ring.Get(keys[i%benchKeys]); !ok {
+				b.Fatal("no node found")
+			}
+			i++
+		}
+	})
+	b.StopTimer()
+
+	atomic.StoreInt32(&done, 1)
+	wg.Wait()
+}
+
+func newBenchHash() *ConsistentHash {
+	h := NewConsistentHash()
+	for i := 0; i < benchNodes; i++ {
+		h.AddWithWeight("node#"+strconv.Itoa(i), TopWeight)
+	}
+
+	return h
+}
+
+func TestAddWithSmallFloatWeight(t *testing.T) {
+	h := NewConsistentHash()
+	h.AddWithWeight("full", TopWeight)
+	h.AddWithFloatWeight("tiny", 0.1)
+	h.AddWithFloatWeight("small", 0.4)
+	h.AddWithFloatWeight("none", 0)
+
+	// the positive weights below half a replica still take a point on the ring
+	for _, node := range []string{"tiny", "small"} {
+		if share := shareOf(h, node); share <= 0 {
+			t.Errorf("expected %s on the ring, got share %v", node, share)
+		}
+	}
+	if share := shareOf(h, "none"); share != 0 {
+		t.Errorf("expected the node without weight not on the ring, got share %v", share)
+	}
+}
@@ -0,0 +1,150 @@
+package hash
+
+import (
+	"math"
+	"sort"
+)
+
+type (
+	// NodeShare is the share of the 64-bit hash space owned by the node.
+	NodeShare struct {
+		Node  interface{}
+		Share float64
+	}
+
+	// ringSnapshot is the sorted distinct points and the nodes on them.
+	ringSnapshot struct {
+		points []uint64
+		nodes  [][]interface{}
+	}
+)
+
+// Distribution returns the shares of the hash space owned by the nodes, sorted by the nodes.
+// The nodes collided on the same point share the arc before it equally, and the nodes without
+// any points are not returned.
+func (h *ConsistentHash) Distribution() []NodeShare {
+	snapshot := h.snapshot()
+	shares := make(map[string]*NodeShare)
+	for i, nodes := range snapshot.nodes {
+		share := snapshot.arc(i) / float64(len(nodes))
+		for _, node := range nodes {
+			nodeRepr := repr(node)
+			if ns, ok := shares[nodeRepr]; ok {
+				ns.Share += share
+			} else {
+				shares[nodeRepr] = &NodeShare{
+					Node:  node,
+					Share: share,
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(shares))
+	for name := range shares {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	distribution := make([]NodeShare, 0, len(names))
+	for _, name := range names {
+		distribution = append(distribution, *shares[name])
+	}
+
+	return distribution
+}
+
+// Diff returns the fraction of the hash space that is owned by different nodes in h and other,
+// that is the fraction of the keys to be moved when changing from h to other.
+// The nodes are compared by their representations.
+func (h *ConsistentHash) Diff(other *ConsistentHash) float64 {
+	from := h.snapshot()
+	to := other.snapshot()
+	if len(from.points) == 0 && len(to.points) == 0 {
+		return 0
+	}
+	if len(from.points) == 0 || len(to.points) == 0 {
+		return 1
+	}
+
+	// the arcs split by the points on both rings are owned by the same nodes in each ring
+	boundaries := make([]uint64, 0, len(from.points)+len(to.points))
+	boundaries = append(boundaries, from.points...)
+	boundaries = append(boundaries, to.points...)
+	boundaries = dedupPoints(boundaries)
+	arcs := ringSnapshot{points: boundaries}
+
+	var moved float64
+	for i, boundary := range boundaries {
+		fromShares := from.owners(boundary)
+		toShares := to.owners(boundary)
+		// the fraction of the arc that stays on the same nodes
+		var kept float64
+		for name, share := range fromShares {
+			kept += math.Min(share, toShares[name])
+		}
+		moved += arcs.arc(i) * (1 - kept)
+	}
+
+	return moved
+}
+
+func (h *ConsistentHash) snapshot() ringSnapshot {
+	return h.load().snapshot()
+}
+
+func (s *ringState) snapshot() ringSnapshot {
+	points := dedupPoints(append([]uint64(nil), s.keys...))
+	nodes := make([][]interface{}, len(points))
+	for i, point := range points {
+		nodes[i] = s.ring[point]
+	}
+
+	return ringSnapshot{
+		points: points,
+		nodes:  nodes,
+	}
+}
+
+// arc returns the fraction of the hash space between the previous point and the i-th point,
+// the first point owns the arc wrapped around from the last point.
+func (s ringSnapshot) arc(i int) float64 {
+	if len(s.points) == 1 {
+		return 1
+	}
+
+	prev := s.points[(i+len(s.points)-1)%len(s.points)]
+	// wraps around for the first point
+	return float64(s.points[i]-prev) / math.Exp2(64)
+}
+
+// owners returns the shares of the nodes owning the hash.
+func (s ringSnapshot) owners(hash uint64) map[string]float64 {
+	index := sort.Search(len(s.points), func(i int) bool {
+		return s.points[i] >= hash
+	}) % len(s.points)
+
+	nodes := s.nodes[index]
+	shares := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		shares[repr(node)] += 1 / float64(len(nodes))
+	}
+
+	return shares
+}
+
+func dedupPoints(points []uint64) []uint64 {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i] < points[j]
+	})
+
+	var n int
+	for i, point := range points {
+		if i == 0 || point != points[n-1] {
+			points[n] = point
+			n++
+		}
+	}
+
+	return points[:n]
+}
@@ -0,0 +1,114 @@
+package hash
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestDistributionEqualNodes(t *testing.T) {
+	const nodes = 10
+	h := newEqualHash(nodes)
+
+	distribution := h.Distribution()
+	if len(distribution) != nodes {
+		t.Fatalf("expected %d nodes, got %d", nodes, len(distribution))
+	}
+
+	var total float64
+	for _, share := range distribution {
+		total += share.Share
+		// 100 virtual nodes per node keep the shares within 25% of the fair share
+		if math.Abs(share.Share*nodes-1) > 0.25 {
+			t.Errorf("share of %v is %.4f, expected about %.4f", share.Node, share.Share, 1.0/nodes)
+		}
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("expected the shares to sum up to 1, got %v", total)
+	}
+}
+
+func TestDistributionWeightedNodes(t *testing.T) {
+	h := NewConsistentHash()
+	h.AddWithWeight("heavy", 4*TopWeight)
+	h.AddWithWeight("light", TopWeight)
+
+	shares := make(map[interface{}]float64)
+	for _, share := range h.Distribution() {
+		shares[share.Node] = share.Share
+	}
+
+	if ratio := shares["heavy"] / shares["light"]; ratio < 3 || ratio > 5 {
+		t.Errorf("expected the heavy node to own about 4 times the light one, got %.2f", ratio)
+	}
+}
+
+func TestDistributionEmpty(t *testing.T) {
+	if distribution := NewConsistentHash().Distribution(); len(distribution) != 0 {
+		t.Errorf("expected no shares on the empty ring, got %v", distribution)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	if diff := newEqualHash(5).Diff(newEqualHash(5)); diff != 0 {
+		t.Errorf("expected no keys moved between the identical rings, got %v", diff)
+	}
+	if diff := NewConsistentHash().Diff(NewConsistentHash()); diff != 0 {
+		t.Errorf("expected no keys moved between the empty rings, got %v", diff)
+	}
+	if diff := NewConsistentHash().Diff(newEqualHash(1)); diff != 1 {
+		t.Errorf("expected all the keys moved from the empty ring, got %v", diff)
+	}
+}
+
+func TestDiffAddNode(t *testing.T) {
+	for _, nodes := range []int{3, 10} {
+		from := newEqualHash(nodes)
+		to := newEqualHash(nodes + 1)
+		added := "node#" + strconv.Itoa(nodes)
+
+		diff := from.Diff(to)
+		// the new node takes about its fair share of the keys, 1/(N+1) with N nodes before
+		expect := 1 / float64(nodes+1)
+		if math.Abs(diff/expect-1) > 0.25 {
+			t.Errorf("adding a node to %d nodes moved %.4f, expected about %.4f", nodes, diff, expect)
+		}
+		// only the keys owned by the new node are moved
+		if share := shareOf(to, added); math.Abs(diff-share) > 1e-9 {
+			t.Errorf("adding a node to %d nodes moved %.4f, but the new node owns %.4f", nodes, diff, share)
+		}
+		if back := to.Diff(from); math.Abs(diff-back) > 1e-9 {
+			t.Errorf("expected the same keys moved back, got %.4f and %.4f", diff, back)
+		}
+	}
+}
+
+func TestDiffRemoveNode(t *testing.T) {
+	from := newEqualHash(10)
+	to := newEqualHash(10)
+	to.Remove("node#3")
+
+	// only the keys owned by the removed node are moved
+	if diff, share := from.Diff(to), shareOf(from, "node#3"); math.Abs(diff-share) > 1e-9 {
+		t.Errorf("removing a node moved %.4f, but the node owned %.4f", diff, share)
+	}
+}
+
+func newEqualHash(nodes int) *ConsistentHash {
+	h := NewConsistentHash()
+	for i := 0; i < nodes; i++ {
+		h.AddWithWeight("node#"+strconv.Itoa(i), TopWeight)
+	}
+
+	return h
+}
+
+func shareOf(h *ConsistentHash, node interface{}) float64 {
+	for _, share := range h.Distribution() {
+		if share.Node == node {
+			return share.Share
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,93 @@
+package hash
+
+import "sync"
+
+// JumpHash is the jump consistent hash (Lamping & Veach) on the buckets of the nodes,
+// each node owns the same number of contiguous buckets as its weight.
+// Only the keys of the changed nodes move when the nodes are added or removed at the end,
+// removing a node in the middle moves the keys of the nodes after it, so it suits
+// the clusters that are mostly scaled out.
+type JumpHash struct {
+	nodes   []weightedNode
+	buckets []int
+	lock    sync.RWMutex
+}
+
+func NewJumpHash() *JumpHash {
+	return &JumpHash{}
+}
+
+func (h *JumpHash) AddWithWeight(node interface{}, weight int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = setWeighted(h.nodes, node, weight)
+	h.build()
+}
+
+func (h *JumpHash) Remove(node interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = removeWeighted(h.nodes, node)
+	h.build()
+}
+
+func (h *JumpHash) Get(v interface{}) (interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.buckets) == 0 {
+		return nil, false
+	}
+
+	return h.nodes[h.buckets[h.bucket(v)]].node, true
+}
+
+// GetN returns the node of v, followed by the nodes of the next buckets.
+func (h *JumpHash) GetN(v interface{}, n int) ([]interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.buckets) == 0 || n <= 0 {
+		return nil, false
+	}
+
+	bucket := h.bucket(v)
+	nodes := make([]interface{}, 0, n)
+	seen := make(map[int]PlaceholderType, n)
+	for i := 0; i < len(h.buckets) && len(nodes) < n; i++ {
+		index := h.buckets[(bucket+i)%len(h.buckets)]
+		if _, ok := seen[index]; !ok {
+			seen[index] = Placeholder
+			nodes = append(nodes, h.nodes[index].node)
+		}
+	}
+
+	return nodes, true
+}
+
+func (h *JumpHash) bucket(v interface{}) int {
+	return jump(Hash([]byte(repr(v))), len(h.buckets))
+}
+
+func (h *JumpHash) build() {
+	h.buckets = h.buckets[:0]
+	for i, node := range h.nodes {
+		for j := 0; j < node.weight; j++ {
+			h.buckets = append(h.buckets, i)
+		}
+	}
+}
+
+// jump returns the bucket in [0, buckets) of the key.
+func jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
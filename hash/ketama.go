@@ -0,0 +1,139 @@
+package hash
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	ketamaPointsPerServer = 160
+	ketamaPointsPerHash   = 4
+)
+
+type (
+	// KetamaHash is the weighted ketama ring compatible with libmemcached, each node has
+	// 160 * weight / average weight points, which are taken 4 by 4 from the md5 digests of "name-i".
+	// The points depend on the weights of all the nodes, so all the keys might move on weight changes.
+	KetamaHash struct {
+		nodes  []weightedNode
+		points []ketamaEntry
+		lock   sync.RWMutex
+	}
+
+	ketamaEntry struct {
+		hash  uint32
+		index int
+	}
+)
+
+func NewKetamaHash() *KetamaHash {
+	return &KetamaHash{}
+}
+
+func (h *KetamaHash) AddWithWeight(node interface{}, weight int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = setWeighted(h.nodes, node, weight)
+	h.build()
+}
+
+func (h *KetamaHash) Remove(node interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = removeWeighted(h.nodes, node)
+	h.build()
+}
+
+func (h *KetamaHash) Get(v interface{}) (interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.points) == 0 {
+		return nil, false
+	}
+
+	return h.nodes[h.points[h.index(v)].index].node, true
+}
+
+// GetN returns at most n distinct nodes for v by walking the ring clockwise.
+func (h *KetamaHash) GetN(v interface{}, n int) ([]interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.points) == 0 || n <= 0 {
+		return nil, false
+	}
+
+	index := h.index(v)
+	nodes := make([]interface{}, 0, n)
+	seen := make(map[int]PlaceholderType, n)
+	for i := 0; i < len(h.points) && len(nodes) < n; i++ {
+		node := h.points[(index+i)%len(h.points)].index
+		if _, ok := seen[node]; !ok {
+			seen[node] = Placeholder
+			nodes = append(nodes, h.nodes[node].node)
+		}
+	}
+
+	return nodes, true
+}
+
+func (h *KetamaHash) index(v interface{}) int {
+	hash := ketamaHash([]byte(repr(v)))
+	return sort.Search(len(h.points), func(i int) bool {
+		return h.points[i].hash >= hash
+	}) % len(h.points)
+}
+
+// build computes the points the same as libmemcached with MEMCACHED_BEHAVIOR_KETAMA_WEIGHTED.
+func (h *KetamaHash) build() {
+	h.points = h.points[:0]
+
+	var totalWeight int
+	for _, node := range h.nodes {
+		totalWeight += node.weight
+	}
+	if totalWeight == 0 {
+		return
+	}
+
+	for i, node := range h.nodes {
+		for j := 0; j < ketamaPoints(node.weight, totalWeight, len(h.nodes))/ketamaPointsPerHash; j++ {
+			digest := md5.Sum([]byte(node.repr + "-" + strconv.Itoa(j)))
+			for k := 0; k < ketamaPointsPerHash; k++ {
+				h.points = append(h.points, ketamaEntry{
+					hash:  ketamaPoint(digest, k),
+					index: i,
+				})
+			}
+		}
+	}
+
+	// the points of the former nodes go first on collisions
+	sort.SliceStable(h.points, func(i, j int) bool {
+		return h.points[i].hash < h.points[j].hash
+	})
+}
+
+// ketamaPoints returns the number of points of the node, computed in float32 as libmemcached does.
+func ketamaPoints(weight, totalWeight, nodes int) int {
+	pct := float32(weight) / float32(totalWeight)
+	perServer := math.Floor(float64(pct*ketamaPointsPerServer/ketamaPointsPerHash*float32(nodes)) + 0.0000000001)
+	return int(perServer) * ketamaPointsPerHash
+}
+
+// ketamaHash returns the hash of the key, the first point of its md5 digest.
+func ketamaHash(data []byte) uint32 {
+	return ketamaPoint(md5.Sum(data), 0)
+}
+
+// ketamaPoint returns the alignment-th little endian uint32 in the md5 digest.
+func ketamaPoint(digest [md5.Size]byte, alignment int) uint32 {
+	return binary.LittleEndian.Uint32(digest[alignment*4:])
+}
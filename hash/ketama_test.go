@@ -0,0 +1,114 @@
+package hash
+
+import "testing"
+
+// the golden vectors are computed by a separate transcription of ketama in libmemcached
+// with MEMCACHED_BEHAVIOR_KETAMA_WEIGHTED, for the servers in newGoldenKetama.
+var (
+	ketamaGoldenPoints = []struct {
+		index int
+		hash  uint32
+		node  string
+	}{
+		{0, 25936326, "10.0.0.4:6379"},
+		{1, 42073797, "10.0.0.2:6379"},
+		{2, 43675136, "10.0.0.4:6379"},
+		{100, 659722348, "10.0.0.1:6379"},
+		{300, 1903206564, "10.0.0.1:6379"},
+		{627, 4292430556, "10.0.0.2:6379"},
+	}
+
+	ketamaGoldenKeys = []struct {
+		key  string
+		hash uint32
+		node string
+	}{
+		{"", 3649838548, "10.0.0.1:6379"},
+		{"foo", 3675831724, "10.0.0.4:6379"},
+		{"bar", 421377335, "10.0.0.3:6379"},
+		{"user:1000", 781738503, "10.0.0.1:6379"},
+		{"session#42", 1146288317, "10.0.0.2:6379"},
+		{"key0", 4060279841, "10.0.0.1:6379"},
+		{"key1", 2497097154, "10.0.0.4:6379"},
+		{"key2", 2854615160, "10.0.0.1:6379"},
+		{"key3", 2237083958, "10.0.0.2:6379"},
+		{"key4", 1273231562, "10.0.0.3:6379"},
+		{"key5", 2593892925, "10.0.0.3:6379"},
+		{"key6", 2717347172, "10.0.0.1:6379"},
+		{"key7", 3444871673, "10.0.0.3:6379"},
+		// after the last point, wraps around to the first one
+		{"wrap1347", 4292487451, "10.0.0.4:6379"},
+	}
+)
+
+func TestKetamaPoints(t *testing.T) {
+	h := newGoldenKetama()
+	if len(h.points) != 628 {
+		t.Fatalf("expected 628 points, got %d", len(h.points))
+	}
+
+	counts := make(map[interface{}]int)
+	for _, point := range h.points {
+		counts[h.nodes[point.index].node]++
+	}
+	for node, expect := range map[string]int{
+		"10.0.0.1:6379": 180,
+		"10.0.0.2:6379": 180,
+		"10.0.0.3:6379": 88,
+		"10.0.0.4:6379": 180,
+	} {
+		if counts[node] != expect {
+			t.Errorf("expected %d points of %s, got %d", expect, node, counts[node])
+		}
+	}
+
+	for _, golden := range ketamaGoldenPoints {
+		point := h.points[golden.index]
+		if point.hash != golden.hash || h.nodes[point.index].node != golden.node {
+			t.Errorf("expected point %d to be %d of %s, got %d of %v", golden.index, golden.hash,
+				golden.node, point.hash, h.nodes[point.index].node)
+		}
+	}
+}
+
+func TestKetamaGet(t *testing.T) {
+	h := newGoldenKetama()
+	for _, golden := range ketamaGoldenKeys {
+		if hash := ketamaHash([]byte(golden.key)); hash != golden.hash {
+			t.Errorf("expected hash of %q to be %d, got %d", golden.key, golden.hash, hash)
+		}
+
+		node, ok := h.Get(golden.key)
+		if !ok || node != golden.node {
+			t.Errorf("expected %q on %s, got %v", golden.key, golden.node, node)
+		}
+
+		nodes, ok := h.GetN(golden.key, 2)
+		if !ok || len(nodes) != 2 || nodes[0] != golden.node || nodes[1] == golden.node {
+			t.Errorf("expected 2 distinct nodes of %q starting with %s, got %v", golden.key, golden.node, nodes)
+		}
+	}
+}
+
+func TestKetamaEmpty(t *testing.T) {
+	h := NewKetamaHash()
+	if _, ok := h.Get("foo"); ok {
+		t.Error("expected no node on the empty ring")
+	}
+
+	h.AddWithWeight("node", 100)
+	h.Remove("node")
+	if _, ok := h.GetN("foo", 1); ok {
+		t.Error("expected no nodes on the ring with all the nodes removed")
+	}
+}
+
+func newGoldenKetama() *KetamaHash {
+	h := NewKetamaHash()
+	h.AddWithWeight("10.0.0.1:6379", 100)
+	h.AddWithWeight("10.0.0.2:6379", 100)
+	h.AddWithWeight("10.0.0.3:6379", 50)
+	h.AddWithWeight("10.0.0.4:6379", 100)
+
+	return h
+}
@@ -0,0 +1,135 @@
+package hash
+
+import (
+	"sort"
+	"sync"
+)
+
+// maglevTableSize is the size of the lookup table, must be a prime much larger than the number of nodes.
+// It's fixed instead of sized by the number of nodes, because the keys only stay on their nodes
+// with the same table size, 65537 keeps the shares within about 1% for up to 650 nodes.
+const maglevTableSize = 65537
+
+// MaglevHash is the maglev hash (Eisenbud et al.) with weights, the slots of the lookup table
+// are filled by the preference lists of the nodes, proportional to their weights.
+// Get is a single table lookup, and only a few keys of the unchanged nodes move on changes.
+type MaglevHash struct {
+	nodes []weightedNode
+	table []int32
+	lock  sync.RWMutex
+}
+
+func NewMaglevHash() *MaglevHash {
+	return &MaglevHash{}
+}
+
+func (h *MaglevHash) AddWithWeight(node interface{}, weight int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = setWeighted(h.nodes, node, weight)
+	h.build()
+}
+
+func (h *MaglevHash) Remove(node interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nodes = removeWeighted(h.nodes, node)
+	h.build()
+}
+
+func (h *MaglevHash) Get(v interface{}) (interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.table) == 0 {
+		return nil, false
+	}
+
+	return h.nodes[h.table[h.slot(v)]].node, true
+}
+
+// GetN returns the node of v, followed by the nodes of the next slots in the lookup table.
+func (h *MaglevHash) GetN(v interface{}, n int) ([]interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.table) == 0 || n <= 0 {
+		return nil, false
+	}
+
+	slot := h.slot(v)
+	nodes := make([]interface{}, 0, n)
+	seen := make(map[int32]PlaceholderType, n)
+	for i := 0; i < len(h.table) && len(nodes) < n; i++ {
+		index := h.table[(slot+i)%len(h.table)]
+		if _, ok := seen[index]; !ok {
+			seen[index] = Placeholder
+			nodes = append(nodes, h.nodes[index].node)
+		}
+	}
+
+	return nodes, true
+}
+
+func (h *MaglevHash) slot(v interface{}) int {
+	return int(Hash([]byte(repr(v))) % maglevTableSize)
+}
+
+// build fills the lookup table, the nodes take turns to fill the next empty slot in their
+// preference lists, and each node takes its turn in proportion to its weight.
+func (h *MaglevHash) build() {
+	// the table only depends on the set of the nodes, not the order they are added
+	sort.Slice(h.nodes, func(i, j int) bool {
+		return h.nodes[i].repr < h.nodes[j].repr
+	})
+
+	var maxWeight int
+	for _, node := range h.nodes {
+		if node.weight > maxWeight {
+			maxWeight = node.weight
+		}
+	}
+	if maxWeight == 0 {
+		h.table = nil
+		return
+	}
+
+	offsets := make([]uint64, len(h.nodes))
+	skips := make([]uint64, len(h.nodes))
+	for i, node := range h.nodes {
+		offsets[i] = Hash([]byte(node.repr)) % maglevTableSize
+		skips[i] = Hash([]byte(innerRepr(node.repr)))%(maglevTableSize-1) + 1
+	}
+
+	table := make([]int32, maglevTableSize)
+	for i := range table {
+		table[i] = -1
+	}
+	next := make([]uint64, len(h.nodes))
+	credits := make([]int, len(h.nodes))
+	for filled := 0; filled < maglevTableSize; {
+		for i, node := range h.nodes {
+			credits[i] += node.weight
+			if credits[i] < maxWeight {
+				continue
+			}
+			credits[i] -= maxWeight
+
+			slot := (offsets[i] + next[i]*skips[i]) % maglevTableSize
+			for table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % maglevTableSize
+			}
+			table[slot] = int32(i)
+			next[i]++
+
+			if filled++; filled == maglevTableSize {
+				break
+			}
+		}
+	}
+
+	h.table = table
+}
@@ -0,0 +1,146 @@
+package hash
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// RendezvousHash is the weighted rendezvous (highest random weight) hash, each node scores the key,
+// and the node with the highest score owns it. Only the keys of the changed node move on any change,
+// and no virtual nodes are needed, but Get costs O(number of nodes).
+type RendezvousHash struct {
+	nodes []rendezvousNode
+	lock  sync.RWMutex
+}
+
+type rendezvousNode struct {
+	weightedNode
+	hash uint64
+}
+
+func NewRendezvousHash() *RendezvousHash {
+	return &RendezvousHash{}
+}
+
+func (h *RendezvousHash) AddWithWeight(node interface{}, weight int) {
+	if weight < 0 {
+		weight = 0
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	nodeRepr := repr(node)
+	for i := range h.nodes {
+		if h.nodes[i].repr == nodeRepr {
+			h.nodes[i].node = node
+			h.nodes[i].weight = weight
+			return
+		}
+	}
+
+	h.nodes = append(h.nodes, rendezvousNode{
+		weightedNode: weightedNode{
+			node:   node,
+			repr:   nodeRepr,
+			weight: weight,
+		},
+		hash: Hash([]byte(nodeRepr)),
+	})
+}
+
+func (h *RendezvousHash) Remove(node interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	nodeRepr := repr(node)
+	for i := range h.nodes {
+		if h.nodes[i].repr == nodeRepr {
+			h.nodes = append(h.nodes[:i], h.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (h *RendezvousHash) Get(v interface{}) (interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	key := Hash([]byte(repr(v)))
+	var node interface{}
+	var found bool
+	var max float64
+	for i := range h.nodes {
+		if h.nodes[i].weight == 0 {
+			continue
+		}
+
+		if s := h.nodes[i].score(key); !found || s > max {
+			node = h.nodes[i].node
+			found = true
+			max = s
+		}
+	}
+
+	return node, found
+}
+
+// GetN returns the n nodes with the highest scores of v.
+func (h *RendezvousHash) GetN(v interface{}, n int) ([]interface{}, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if n <= 0 {
+		return nil, false
+	}
+
+	key := Hash([]byte(repr(v)))
+	type scored struct {
+		node  interface{}
+		score float64
+	}
+	candidates := make([]scored, 0, len(h.nodes))
+	for i := range h.nodes {
+		if h.nodes[i].weight > 0 {
+			candidates = append(candidates, scored{
+				node:  h.nodes[i].node,
+				score: h.nodes[i].score(key),
+			})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+
+	nodes := make([]interface{}, n)
+	for i := range nodes {
+		nodes[i] = candidates[i].node
+	}
+
+	return nodes, true
+}
+
+// score is the logarithmic method of the weighted rendezvous hash, weight / -ln(u),
+// where u is uniformly distributed in (0, 1) by the key and the node.
+func (n rendezvousNode) score(key uint64) float64 {
+	u := (float64(mix(key^n.hash)>>11) + 0.5) / (1 << 53)
+	return float64(n.weight) / -math.Log(u)
+}
+
+// mix is the finalizer of splitmix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
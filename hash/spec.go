@@ -0,0 +1,260 @@
+package hash
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// The portable ring specs, which let the other clients map the keys to the same nodes.
+//
+// The consistent hash ring (algorithm "consistent", hash "murmur3"):
+//   - the points of a node are murmur3.Sum64(name + strconv.Itoa(i)) for i in [0, points),
+//     where name is utils.Repr(node), which is NodeConf.String() in the cache package;
+//   - the hash of a key is murmur3.Sum64(key), and the key goes to the first point >= hash,
+//     wrapping around to the first point on the ring;
+//   - if several nodes are on the same point, the node is nodes[murmur3.Sum64("16777619:" + key) % len(nodes)],
+//     in the order of the nodes in the exported points.
+//
+// The ketama ring (algorithm "ketama", hash "md5") is compatible with libmemcached weighted ketama:
+//   - a node has floor(weight / totalWeight * 160 / 4 * len(nodes) + 1e-10) * 4 points, in float32;
+//   - the i-th md5 digest of a node is md5(name + "-" + strconv.Itoa(i)), each digest has 4 points,
+//     the little endian uint32s at offsets 0, 4, 8 and 12;
+//   - the hash of a key is the little endian uint32 at offset 0 of md5(key), and the key goes to
+//     the first point >= hash, wrapping around, on collisions the node in the exported points goes.
+const (
+	ConsistentAlgorithm = "consistent"
+	KetamaAlgorithm     = "ketama"
+
+	Murmur3 = "murmur3"
+	MD5     = "md5"
+)
+
+type (
+	// RingSpec is the portable and deterministic spec of a ring.
+	RingSpec struct {
+		Algorithm string `json:"algorithm"`
+		Hash      string `json:"hash"`
+		// the default number of points of a node with TopWeight, only for the consistent algorithm
+		Replicas  int        `json:"replicas,omitempty"`
+		TopWeight int        `json:"topWeight,omitempty"`
+		Nodes     []NodeSpec `json:"nodes"`
+		// the sorted points, optional, exported to check the implementations against
+		Points []PointSpec `json:"points,omitempty"`
+	}
+
+	// NodeSpec is the spec of a node on the ring.
+	NodeSpec struct {
+		Name string `json:"name"`
+		// the weight of the node, only for the ketama algorithm
+		Weight int `json:"weight,omitempty"`
+		Points int `json:"points"`
+	}
+
+	// PointSpec is a point on the ring, the hash is a string, because not all languages have uint64.
+	PointSpec struct {
+		Hash  uint64   `json:"hash,string"`
+		Nodes []string `json:"nodes"`
+	}
+
+	// Exportable is a Balancer that can be exported as a RingSpec.
+	Exportable interface {
+		Balancer
+		// Spec returns the spec of the ring, with the points if withPoints is true.
+		Spec(withPoints bool) RingSpec
+	}
+)
+
+var (
+	_ Exportable = (*ConsistentHash)(nil)
+	_ Exportable = (*KetamaHash)(nil)
+)
+
+// Spec returns the spec of the ring, the nodes are named by their representations.
+func (h *ConsistentHash) Spec(withPoints bool) RingSpec {
+	s := h.load()
+	nodes := make([]NodeSpec, 0, len(s.points))
+	for name, points := range s.points {
+		nodes = append(nodes, NodeSpec{
+			Name:   name,
+			Points: points,
+		})
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	spec := RingSpec{
+		Algorithm: ConsistentAlgorithm,
+		Hash:      h.hashName,
+		Replicas:  h.replicas,
+		TopWeight: TopWeight,
+		Nodes:     nodes,
+	}
+	if withPoints {
+		snapshot := s.snapshot()
+		spec.Points = make([]PointSpec, len(snapshot.points))
+		for i, point := range snapshot.points {
+			spec.Points[i] = PointSpec{
+				Hash:  point,
+				Nodes: reprs(snapshot.nodes[i]),
+			}
+		}
+	}
+
+	return spec
+}
+
+// Spec returns the spec of the ring, the nodes are named by their representations.
+func (h *KetamaHash) Spec(withPoints bool) RingSpec {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	spec := RingSpec{
+		Algorithm: KetamaAlgorithm,
+		Hash:      MD5,
+		Nodes:     make([]NodeSpec, 0, len(h.nodes)),
+	}
+
+	points := make([]int, len(h.nodes))
+	for _, point := range h.points {
+		points[point.index]++
+	}
+	for i, node := range h.nodes {
+		spec.Nodes = append(spec.Nodes, NodeSpec{
+			Name:   node.repr,
+			Weight: node.weight,
+			Points: points[i],
+		})
+	}
+
+	if withPoints {
+		for _, point := range h.points {
+			hash := uint64(point.hash)
+			name := h.nodes[point.index].repr
+			if n := len(spec.Points); n > 0 && spec.Points[n-1].Hash == hash {
+				spec.Points[n-1].Nodes = append(spec.Points[n-1].Nodes, name)
+			} else {
+				spec.Points = append(spec.Points, PointSpec{
+					Hash:  hash,
+					Nodes: []string{name},
+				})
+			}
+		}
+	}
+
+	return spec
+}
+
+// ParseRing parses the ring from the json of a RingSpec.
+func ParseRing(data []byte) (Exportable, error) {
+	var spec RingSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	return NewRingFromSpec(spec)
+}
+
+// NewRingFromSpec returns the ring of the spec, the nodes on the ring are the node names.
+// If the points are in the spec, they are checked against the built ring, and the order of
+// the collided nodes follows them.
+func NewRingFromSpec(spec RingSpec) (Exportable, error) {
+	var ring Exportable
+	switch spec.Algorithm {
+	case ConsistentAlgorithm:
+		if spec.Hash != Murmur3 {
+			return nil, fmt.Errorf("unsupported hash %q of the consistent algorithm", spec.Hash)
+		}
+		if spec.TopWeight != 0 && spec.TopWeight != TopWeight {
+			return nil, fmt.Errorf("unsupported top weight %d", spec.TopWeight)
+		}
+
+		h := NewCustomConsistentHash(spec.Replicas, nil)
+		for _, node := range specNodes(spec) {
+			h.AddWithReplicas(node.Name, node.Points)
+		}
+		ring = h
+	case KetamaAlgorithm:
+		if spec.Hash != MD5 {
+			return nil, fmt.Errorf("unsupported hash %q of the ketama algorithm", spec.Hash)
+		}
+
+		h := NewKetamaHash()
+		for _, node := range specNodes(spec) {
+			h.AddWithWeight(node.Name, node.Weight)
+		}
+		ring = h
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", spec.Algorithm)
+	}
+
+	if len(spec.Points) > 0 {
+		if err := checkPoints(spec.Points, ring.Spec(true).Points); err != nil {
+			return nil, err
+		}
+	}
+
+	return ring, nil
+}
+
+// specNodes returns the nodes in the order they first appear in the points, so that
+// the collided nodes are added in the same order, followed by the nodes without points.
+func specNodes(spec RingSpec) []NodeSpec {
+	if len(spec.Points) == 0 {
+		return spec.Nodes
+	}
+
+	nodes := make(map[string]NodeSpec, len(spec.Nodes))
+	for _, node := range spec.Nodes {
+		nodes[node.Name] = node
+	}
+
+	ordered := make([]NodeSpec, 0, len(spec.Nodes))
+	add := func(name string) {
+		if node, ok := nodes[name]; ok {
+			ordered = append(ordered, node)
+			delete(nodes, name)
+		}
+	}
+	for _, point := range spec.Points {
+		for _, name := range point.Nodes {
+			add(name)
+		}
+	}
+	for _, node := range spec.Nodes {
+		add(node.Name)
+	}
+
+	return ordered
+}
+
+func checkPoints(expect, actual []PointSpec) error {
+	if len(expect) != len(actual) {
+		return fmt.Errorf("mismatched points, expect %d, actual %d", len(expect), len(actual))
+	}
+
+	for i := range expect {
+		if expect[i].Hash != actual[i].Hash || len(expect[i].Nodes) != len(actual[i].Nodes) {
+			return fmt.Errorf("mismatched point %d, expect %d, actual %d", i, expect[i].Hash, actual[i].Hash)
+		}
+		for j := range expect[i].Nodes {
+			if expect[i].Nodes[j] != actual[i].Nodes[j] {
+				return fmt.Errorf("mismatched nodes on point %d, expect %q, actual %q",
+					expect[i].Hash, expect[i].Nodes[j], actual[i].Nodes[j])
+			}
+		}
+	}
+
+	return nil
+}
+
+func reprs(nodes []interface{}) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = repr(node)
+	}
+
+	return names
+}
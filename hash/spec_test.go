@@ -0,0 +1,87 @@
+package hash
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSpecRoundTrip(t *testing.T) {
+	weighted := NewConsistentHash()
+	for i := 0; i < 5; i++ {
+		weighted.AddWithWeight("node#"+strconv.Itoa(i), TopWeight/2*(i+1))
+	}
+
+	for _, test := range []struct {
+		name string
+		ring Exportable
+	}{
+		{name: "consistent", ring: newEqualHash(5)},
+		{name: "consistent-weighted", ring: weighted},
+		{name: "consistent-custom-replicas", ring: NewCustomConsistentHash(300, nil)},
+		{name: "ketama", ring: newGoldenKetama()},
+	} {
+		for _, withPoints := range []bool{false, true} {
+			if len(test.ring.Spec(false).Nodes) == 0 {
+				test.ring.AddWithWeight("node", TopWeight)
+			}
+
+			spec := test.ring.Spec(withPoints)
+			data, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ParseRing(data)
+			if err != nil {
+				t.Fatalf("%s: failed to parse the spec: %v", test.name, err)
+			}
+			// the nodes are added in the order of the points, to keep the order of the collided nodes
+			actual := parsed.Spec(withPoints)
+			sortNodes(spec.Nodes)
+			sortNodes(actual.Nodes)
+			if !reflect.DeepEqual(spec, actual) {
+				t.Errorf("%s: expected the same spec after the round trip, with points: %t", test.name, withPoints)
+			}
+
+			for i := 0; i < 1000; i++ {
+				key := "key#" + strconv.Itoa(i)
+				expect, _ := test.ring.Get(key)
+				actual, _ := parsed.Get(key)
+				if repr(expect) != repr(actual) {
+					t.Fatalf("%s: expected %q on %v, got %v", test.name, key, expect, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestSpecMismatchedPoints(t *testing.T) {
+	spec := newEqualHash(3).Spec(true)
+	spec.Points[10].Hash++
+
+	if _, err := NewRingFromSpec(spec); err == nil {
+		t.Error("expected the tampered points to be rejected")
+	}
+}
+
+func TestSpecUnsupported(t *testing.T) {
+	for _, spec := range []RingSpec{
+		{Algorithm: "unknown", Hash: Murmur3},
+		{Algorithm: ConsistentAlgorithm, Hash: MD5},
+		{Algorithm: ConsistentAlgorithm, Hash: Murmur3, TopWeight: TopWeight + 1},
+		{Algorithm: KetamaAlgorithm, Hash: Murmur3},
+	} {
+		if _, err := NewRingFromSpec(spec); err == nil {
+			t.Errorf("expected the spec %+v to be rejected", spec)
+		}
+	}
+}
+
+func sortNodes(nodes []NodeSpec) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+}
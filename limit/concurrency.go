@@ -0,0 +1,28 @@
+package limit
+
+// ConcurrencyLimiter limits the number of the concurrent calls.
+type ConcurrencyLimiter struct {
+	pool chan struct{}
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter that allows n concurrent calls.
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		pool: make(chan struct{}, n),
+	}
+}
+
+// TryBorrow borrows a slot without blocking, returns false if there are no slots left.
+func (l *ConcurrencyLimiter) TryBorrow() bool {
+	select {
+	case l.pool <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Return returns the borrowed slot.
+func (l *ConcurrencyLimiter) Return() {
+	<-l.pool
+}
@@ -0,0 +1,49 @@
+package limit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenLimiter is a token bucket, which is refilled at rate tokens per second, and holds at most burst tokens.
+type TokenLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+// NewTokenLimiter returns a TokenLimiter with a full bucket.
+func NewTokenLimiter(rate float64, burst int) *TokenLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &TokenLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket, returns false if the bucket is empty.
+func (l *TokenLimiter) Allow() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
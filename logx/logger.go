@@ -0,0 +1,84 @@
+package logx
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Logger is a leveled, structured logger.
+	// Implementations must be safe for concurrent use.
+	Logger interface {
+		Debug(msg string, fields ...Field)
+		Info(msg string, fields ...Field)
+		Warn(msg string, fields ...Field)
+		Error(msg string, fields ...Field)
+	}
+
+	// Field is a key/value pair attached to a log entry.
+	Field struct {
+		Key   string
+		Value interface{}
+	}
+
+	// holder keeps atomic.Value storing the same concrete type.
+	holder struct {
+		logger Logger
+	}
+)
+
+var global atomic.Value
+
+func init() {
+	global.Store(holder{logger: NewStdLogger(nil, LevelInfo)})
+}
+
+// SetLogger sets the global logger, a nil logger disables logging.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = Nop
+	}
+	global.Store(holder{logger: l})
+}
+
+// GetLogger returns the global logger.
+func GetLogger() Logger {
+	return global.Load().(holder).logger
+}
+
+// OrGlobal returns l if it's not nil, otherwise the global logger.
+func OrGlobal(l Logger) Logger {
+	if l != nil {
+		return l
+	}
+
+	return GetLogger()
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Node(addr string) Field {
+	return Field{Key: "node", Value: addr}
+}
+
+func Key(key string) Field {
+	return Field{Key: "key", Value: key}
+}
+
+func Keys(keys []string) Field {
+	return Field{Key: "keys", Value: keys}
+}
+
+func Datasource(datasource string) Field {
+	return Field{Key: "datasource", Value: datasource}
+}
+
+func Err(err error) Field {
+	return Field{Key: "err", Value: err}
+}
+
+func Duration(d time.Duration) Field {
+	return Field{Key: "duration", Value: d}
+}
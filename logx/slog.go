@@ -0,0 +1,55 @@
+//go:build go1.21
+// +build go1.21
+
+package logx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger backed by l, slog.Default() is used if l is nil.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return slogLogger{logger: l}
+}
+
+func (l slogLogger) Debug(msg string, fields ...Field) {
+	l.log(slog.LevelDebug, msg, fields)
+}
+
+func (l slogLogger) Info(msg string, fields ...Field) {
+	l.log(slog.LevelInfo, msg, fields)
+}
+
+func (l slogLogger) Warn(msg string, fields ...Field) {
+	l.log(slog.LevelWarn, msg, fields)
+}
+
+func (l slogLogger) Error(msg string, fields ...Field) {
+	l.log(slog.LevelError, msg, fields)
+}
+
+func (l slogLogger) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		if err, ok := field.Value.(error); ok {
+			attrs = append(attrs, slog.String(field.Key, err.Error()))
+		} else {
+			attrs = append(attrs, slog.Any(field.Key, field.Value))
+		}
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
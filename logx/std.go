@@ -0,0 +1,92 @@
+package logx
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Nop discards all log entries.
+var Nop Logger = nopLogger{}
+
+type (
+	stdLogger struct {
+		logger *log.Logger
+		level  Level
+	}
+
+	nopLogger struct{}
+)
+
+// NewStdLogger returns a Logger that writes to l, entries below level are dropped.
+// The standard logger of the log package is used if l is nil.
+func NewStdLogger(l *log.Logger, level Level) Logger {
+	return stdLogger{
+		logger: l,
+		level:  level,
+	}
+}
+
+func (l stdLogger) Debug(msg string, fields ...Field) {
+	l.output(LevelDebug, msg, fields)
+}
+
+func (l stdLogger) Info(msg string, fields ...Field) {
+	l.output(LevelInfo, msg, fields)
+}
+
+func (l stdLogger) Warn(msg string, fields ...Field) {
+	l.output(LevelWarn, msg, fields)
+}
+
+func (l stdLogger) Error(msg string, fields ...Field) {
+	l.output(LevelError, msg, fields)
+}
+
+func (l stdLogger) output(level Level, msg string, fields []Field) {
+	if level < l.level {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(level.String())
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+	for _, field := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		buf.WriteString(formatValue(field.Value))
+	}
+
+	if l.logger != nil {
+		l.logger.Output(3, buf.String())
+	} else {
+		log.Output(3, buf.String())
+	}
+}
+
+func formatValue(v interface{}) string {
+	var s string
+	switch vt := v.(type) {
+	case string:
+		s = vt
+	case error:
+		s = vt.Error()
+	case fmt.Stringer:
+		s = vt.String()
+	default:
+		s = fmt.Sprint(vt)
+	}
+
+	return strconv.Quote(s)
+}
+
+func (nopLogger) Debug(string, ...Field) {}
+
+func (nopLogger) Info(string, ...Field) {}
+
+func (nopLogger) Warn(string, ...Field) {}
+
+func (nopLogger) Error(string, ...Field) {}
@@ -2,6 +2,7 @@ package sqlcache
 
 import (
 	"database/sql"
+	"net/http"
 	"redis-cache/cache"
 	"redis-cache/singleflight"
 	"time"
@@ -114,3 +115,8 @@ func (cc CachedConn) SetCache(key string, v interface{}) error {
 func (cc CachedConn) Transact(fn func(txExec) error) error {
 	return cc.db.Transact(fn)
 }
+
+// AdminHandler returns an http.Handler to inspect and operate the cache, see cache.NewAdminHandler.
+func (cc CachedConn) AdminHandler() http.Handler {
+	return cache.NewAdminHandler(cc.cache)
+}
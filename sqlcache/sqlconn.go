@@ -2,6 +2,7 @@ package sqlcache
 
 import (
 	"database/sql"
+	"redis-cache/logx"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -28,6 +29,7 @@ type (
 		datasource string
 		beginTx    beginnable
 		accept     func(error) bool
+		logger     logx.Logger
 	}
 )
 
@@ -48,7 +50,7 @@ func (db *commonSqlConn) Exec(q string, args ...interface{}) (result sql.Result,
 	var conn *sqlx.DB
 	conn, err = getSqlConn(db.driverName, db.datasource)
 	if err != nil {
-		logInstanceError(db.datasource, err)
+		logInstanceError(db.logger, db.datasource, err)
 		return
 	}
 
@@ -60,21 +62,28 @@ func (db *commonSqlConn) Exec(q string, args ...interface{}) (result sql.Result,
 func (db *commonSqlConn) QueryRow(v interface{}, q string, args ...interface{}) error {
 	conn, err := getSqlConn(db.driverName, db.datasource)
 	if err != nil {
-		logInstanceError(db.datasource, err)
+		logInstanceError(db.logger, db.datasource, err)
 		return err
 	}
-	return conn.Get(v, q, args...)
 
+	return conn.Get(v, q, args...)
 }
 
 func (db *commonSqlConn) QueryRows(v interface{}, q string, args ...interface{}) error {
 	conn, err := getSqlConn(db.driverName, db.datasource)
 	if err != nil {
-		logInstanceError(db.datasource, err)
+		logInstanceError(db.logger, db.datasource, err)
 		return err
 	}
+
 	return conn.Select(v, q, args...)
+}
 
+// WithLogger sets the logger of the connection, the global logger is used if not set.
+func WithLogger(logger logx.Logger) SqlOption {
+	return func(conn *commonSqlConn) {
+		conn.logger = logger
+	}
 }
 
 func (db *commonSqlConn) Transact(fn func(txExec) error) error {
@@ -40,7 +40,7 @@ func begin(db *sqlx.DB) (trans, error) {
 func transact(db *commonSqlConn, b beginnable, fn func(txExec) error) (err error) {
 	conn, err := getSqlConn(db.driverName, db.datasource)
 	if err != nil {
-		logInstanceError(db.datasource, err)
+		logInstanceError(db.logger, db.datasource, err)
 		return err
 	}
 
@@ -1,7 +1,7 @@
 package sqlcache
 
 import (
-	"log"
+	"redis-cache/logx"
 	"strings"
 )
 
@@ -16,7 +16,6 @@ func desensitize(datasource string) string {
 	return datasource
 }
 
-func logInstanceError(datasource string, err error) {
-	datasource = desensitize(datasource)
-	log.Printf("Error on getting sql instance of %s: %v", datasource, err)
+func logInstanceError(logger logx.Logger, datasource string, err error) {
+	logx.OrGlobal(logger).Error("failed to get sql instance", logx.Datasource(desensitize(datasource)), logx.Err(err))
 }